@@ -5,33 +5,51 @@ import (
 	"errors"
 	"flag"
 	"github.com/samanazadi/load-balancer/configs"
-	"github.com/samanazadi/load-balancer/internal/algorithm"
+	"github.com/samanazadi/load-balancer/internal/admin"
 	"github.com/samanazadi/load-balancer/internal/app"
-	"github.com/samanazadi/load-balancer/internal/checker"
+	"github.com/samanazadi/load-balancer/internal/proxyproto"
+	"github.com/samanazadi/load-balancer/internal/upgrade"
+	"github.com/samanazadi/load-balancer/pkg/algorithm"
+	"github.com/samanazadi/load-balancer/pkg/checker"
 	"github.com/samanazadi/load-balancer/pkg/logging"
+	"github.com/samanazadi/load-balancer/pkg/retry"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
 	"syscall"
+	"time"
 )
 
+// upgradeTimeout is how long the old process waits for the new one to serve during a binary upgrade
+const upgradeTimeout = 30 * time.Second
+
 func main() {
 	// logging
 	logging.Init()
 	logging.Logger.Printf("starting load balancer...")
 
 	// command line flags
-	var cfgPath string //configs directory path
-	flag.StringVar(&cfgPath, "c", "/etc/load-balancer", "configs directory")
+	var cfgPath string //configs directory or config file path
+	flag.StringVar(&cfgPath, "c", "/etc/load-balancer",
+		"configs directory or config file (.json, .yaml, .yml, .toml), empty for environment variables and flags only")
+	var watchPeriod time.Duration // config files polling period
+	flag.DurationVar(&watchPeriod, "watch", 0, "reload configs when files change, polled at this period (0 disables)")
+	var checkConfig bool // validate configs and exit
+	flag.BoolVar(&checkConfig, "check-config", false, "validate configs and exit without serving")
+	overrides := configs.NewFlags(flag.CommandLine) // a flag per config key
 	flag.Parse()
-	logging.Logger.Printf("configs directory: %s", cfgPath)
+	logging.Logger.Printf("configs: %s", cfgPath)
 
-	// config
-	cfg, err := configs.New(cfgPath)
+	// config, overridden by environment variables and flags
+	cfg, err := configs.Load(cfgPath, overrides)
 	if err != nil {
 		logging.Logger.Fatal(err)
 	}
+	for _, warning := range cfg.Warnings() {
+		logging.Logger.Printf("warning: %s", warning)
+	}
 	logging.Logger.Printf("configs loaded")
 
 	// checker
@@ -50,42 +68,194 @@ func main() {
 	}
 	logging.Logger.Printf("algorithm created")
 
+	// retry policy
+	pol, err := retry.New(cfg)
+	if err != nil {
+		logging.Logger.Fatal(err)
+	}
+	logging.Logger.Printf("retry policy created")
+
+	// nodes, created by the load balancer
+	if err := app.CheckNodes(cfg); err != nil {
+		logging.Logger.Fatal(err)
+	}
+	if checkConfig {
+		logging.Logger.Printf("configs are valid")
+		return
+	}
+
 	// load balancer
 	stopPHC := make(chan bool, 1) // passive health check
 	defer close(stopPHC)
 	donePHC := make(chan bool, 1) // passive health check
 	defer close(donePHC)
-	lb := app.New(cfg, chk, alg, stopPHC, donePHC)
+	lb, err := app.New(cfg, chk, alg, pol, stopPHC, donePHC)
+	if err != nil {
+		logging.Logger.Fatal(err)
+	}
 	logging.Logger.Println("load balancer created")
 
+	requests, cancelRequests := context.WithCancel(context.Background()) // canceled to force close on shutdown
+	defer cancelRequests()
 	server := &http.Server{
-		Addr:    ":" + strconv.Itoa(cfg.Port),
-		Handler: lb,
+		Addr:        ":" + strconv.Itoa(cfg.Port),
+		Handler:     lb,
+		BaseContext: func(net.Listener) context.Context { return requests },
+	}
+	tcpLn, err := upgrade.Listen(server.Addr) // inherited from the old process on upgrades
+	if err != nil {
+		logging.Logger.Fatalf("cannot listen on port %d: %s", cfg.Port, err.Error())
+	}
+	ln := tcpLn
+	if cfg.ProxyProtocol.Enabled {
+		ln, err = proxyproto.NewListener(ln, cfg)
+		if err != nil {
+			logging.Logger.Fatal(err)
+		}
+		logging.Logger.Printf("PROXY protocol enabled for %v", cfg.ProxyProtocol.TrustedCIDRs)
 	}
 	go func() {
 		logging.Logger.Printf("load balancer started at port %d", cfg.Port)
-		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
 			logging.Logger.Printf("cannot start load balancer: %s", err.Error())
 		}
 	}()
 
-	// graceful shutdown
+	// admin API
+	var adminServer *http.Server
+	adminAddr := ""
+	if cfg.Admin.Port != 0 {
+		adminAddr = admin.Addr(cfg)
+	}
+	adminLn, err := upgrade.ListenAdmin(adminAddr) // inherited from the old process on upgrades
+	if err != nil {
+		logging.Logger.Fatalf("cannot listen on %s: %s", adminAddr, err.Error())
+	}
+	if adminLn != nil {
+		adminServer = &http.Server{
+			Addr:    adminAddr,
+			Handler: admin.New(lb, cfg),
+		}
+		go func() {
+			logging.Logger.Printf("admin API started at %s", adminServer.Addr)
+			if err := adminServer.Serve(adminLn); !errors.Is(err, http.ErrServerClosed) {
+				logging.Logger.Printf("cannot start admin API: %s", err.Error())
+			}
+		}()
+	}
+
+	// an old process waiting for this one to serve stops accepting
+	if err := upgrade.Ready(); err != nil {
+		logging.Logger.Printf("upgrade: %s", err.Error())
+	}
+
+	// config reload on SIGHUP and file changes, binary upgrade on SIGUSR2, graceful shutdown on SIGINT and SIGTERM
 	sigs := make(chan os.Signal, 1)
 	defer close(sigs)
+	signal.Notify(sigs, append([]os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP}, upgradeSignals...)...)
+
+	var changes <-chan struct{} // nil if not watching
+	stopWatch := make(chan struct{})
+	if watchPeriod > 0 && cfgPath != "" {
+		changes = configs.Watch(cfgPath, watchPeriod, stopWatch)
+		logging.Logger.Printf("watching config files every %s", watchPeriod)
+	}
+	upgraded := false
+	for running := true; running; {
+		select {
+		case sig := <-sigs:
+			switch {
+			case sig == syscall.SIGHUP:
+				logging.Logger.Print("SIGHUP received, reloading configs")
+				reload(lb, cfgPath, overrides)
+			case isUpgradeSignal(sig):
+				logging.Logger.Printf("%s received, starting new process", sig)
+				p, err := upgrade.Start(tcpLn, adminLn, upgradeTimeout)
+				if err != nil {
+					logging.Logger.Printf("upgrade failed: %s", err.Error())
+					break
+				}
+				logging.Logger.Printf("new process %d is ready, draining", p.Pid)
+				if adminServer != nil {
+					go adminServer.Shutdown(context.Background()) // the new process serves the admin API
+				}
+				running, upgraded = false, true
+			default:
+				running = false
+			}
+		case <-changes:
+			logging.Logger.Print("configs changed, reloading")
+			reload(lb, cfgPath, overrides)
+		}
+	}
+	close(stopWatch)
 
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-	<-sigs
+	// lameduck, not needed on upgrades since the new process is serving
+	if lameduck := cfg.Shutdown.Lameduck.Std(); lameduck > 0 && !upgraded {
+		lb.EnterLameduck()
+		logging.Logger.Printf("lameduck, reporting not ready for %s", lameduck)
+		time.Sleep(lameduck)
+	}
 	stopPHC <- true
 
+	// drain in-flight requests and upgraded connections, then force close
+	ctx := context.Background()
+	if timeout := cfg.Shutdown.DrainTimeout.Std(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 	logging.Logger.Print("awaiting load balancer to stop")
-	err = server.Shutdown(context.Background())
-	if err != nil {
-		logging.Logger.Printf("load balancer stopped with error: %s", err)
+	if err := drain(ctx, server, lb); err != nil {
+		logging.Logger.Printf("load balancer didn't drain, closing connections: %s", err.Error())
+		cancelRequests()
+		if err := server.Close(); err != nil {
+			logging.Logger.Printf("load balancer stopped with error: %s", err)
+		}
 	} else {
 		logging.Logger.Print("load balancer stopped")
 	}
 
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			adminServer.Close()
+			logging.Logger.Printf("admin API stopped with error: %s", err)
+		}
+	}
+
 	logging.Logger.Print("awaiting passive health check to stop")
 	<-donePHC
 	logging.Logger.Print("passive health check stopped")
 }
+
+// drain stops accepting and waits until requests and upgraded connections, which the server doesn't track, finish
+func drain(ctx context.Context, server *http.Server, lb *app.LoadBalancer) error {
+	if err := server.Shutdown(ctx); err != nil {
+		return err
+	}
+	t := time.NewTicker(100 * time.Millisecond)
+	defer t.Stop()
+	for lb.Active() > 0 {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// reload applies the configs of cfgPath with the same overrides, keeping the current ones if they are invalid
+func reload(lb *app.LoadBalancer, cfgPath string, overrides *configs.Flags) {
+	cfg, err := configs.Load(cfgPath, overrides)
+	if err != nil {
+		logging.Logger.Printf("config reload rejected: %s", err.Error())
+		return
+	}
+	for _, warning := range cfg.Warnings() {
+		logging.Logger.Printf("warning: %s", warning)
+	}
+	if err := lb.Reload(cfg); err != nil {
+		logging.Logger.Printf("config reload rejected: %s", err.Error())
+	}
+}
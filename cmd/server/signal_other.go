@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// upgradeSignals is empty, binary upgrades need SIGUSR2 which is only available on Unix
+var upgradeSignals []os.Signal
+
+// isUpgradeSignal reports false, binary upgrades are only supported on Unix
+func isUpgradeSignal(os.Signal) bool {
+	return false
+}
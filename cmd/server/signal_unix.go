@@ -0,0 +1,16 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// upgradeSignals start a binary upgrade
+var upgradeSignals = []os.Signal{syscall.SIGUSR2}
+
+// isUpgradeSignal reports whether sig starts a binary upgrade
+func isUpgradeSignal(sig os.Signal) bool {
+	return sig == syscall.SIGUSR2
+}
@@ -2,82 +2,287 @@ package configs
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 )
 
+// ActiveHealthCheck configures the circuit breaker of nodes, driven by the outcome of real requests
 type ActiveHealthCheck struct {
-	MaxRetry   int `json:"maxRetry"`
+	MaxRetry         int     `json:"maxRetry"`         // consecutive failed requests which open the circuit
+	ErrorRate        float64 `json:"errorRate"`        // failure ratio over window which opens the circuit
+	Window           int     `json:"window"`           // error rate window in seconds
+	MinRequests      int     `json:"minRequests"`      // min requests in window for error rate
+	OpenDuration     int     `json:"openDuration"`     // milliseconds before probing an open circuit
+	HalfOpenRequests int     `json:"halfOpenRequests"` // successful probes which close the circuit
+
+	// Deprecated: RetryDelay is ignored, failed requests are retried with retry.backoffBase and retry.backoffMax
 	RetryDelay int `json:"retryDelay"`
 }
 
+// PassiveHealthCheck schedules the checks of every node independently. Durations are strings like "500ms" or numbers
+// of seconds.
 type PassiveHealthCheck struct {
-	Period  int `json:"period"`
-	Timeout int `json:"timeout"`
+	Period          Duration `json:"period"`
+	Timeout         Duration `json:"timeout"`
+	UnhealthyPeriod Duration `json:"unhealthyPeriod"` // period while a node is down, period by default
+	Jitter          float64  `json:"jitter"`          // random fraction of the period added or subtracted, 0 to 1
+	MaxConcurrent   int      `json:"maxConcurrent"`   // max checks running at the same time, 0 for unlimited
+	InitialState    string   `json:"initialState"`    // "up" (default), "down" or "unknown" until checked
+	StartupTimeout  Duration `json:"startupTimeout"`  // bound of the check of all nodes before serving, 0 disables
+}
+
+// OutlierDetection ejects nodes based on observed responses. Durations are in milliseconds.
+type OutlierDetection struct {
+	Enabled                   bool    `json:"enabled"`
+	Interval                  int     `json:"interval"` // success rate and latency analysis interval
+	BaseEjectionTime          int     `json:"baseEjectionTime"`
+	MaxEjectionTime           int     `json:"maxEjectionTime"`
+	MaxEjectionPercent        int     `json:"maxEjectionPercent"`
+	Consecutive5xx            int     `json:"consecutive5xx"`
+	ConsecutiveGatewayFailure int     `json:"consecutiveGatewayFailure"`
+	SuccessRateStdevFactor    float64 `json:"successRateStdevFactor"`
+	LatencyStdevFactor        float64 `json:"latencyStdevFactor"`
+	MinHosts                  int     `json:"minHosts"`
+	RequestVolume             int     `json:"requestVolume"`
 }
 
 type HealthCheck struct {
-	Active  ActiveHealthCheck  `json:"active"`
-	Passive PassiveHealthCheck `json:"passive"`
+	Active           ActiveHealthCheck  `json:"active"`
+	Passive          PassiveHealthCheck `json:"passive"`
+	OutlierDetection OutlierDetection   `json:"outlierDetection"`
+}
+
+// RetryBudget limits retries to Ratio of requests plus MinPerSecond over a sliding window of Window seconds
+type RetryBudget struct {
+	Ratio        float64 `json:"ratio"`
+	MinPerSecond int     `json:"minPerSecond"`
+	Window       int     `json:"window"`
+}
+
+// SlowStart ramps up the weight of recovering nodes
+type SlowStart struct {
+	Window    int     `json:"window"`    // seconds, 0 disables slow start
+	Mode      string  `json:"mode"`      // linear or exponential
+	MinWeight float64 `json:"minWeight"` // fraction of the weight at the start of the window, default 0.1
+}
+
+// Retry is the retry policy of failed requests. Durations are in milliseconds.
+type Retry struct {
+	MaxAttempts int      `json:"maxAttempts"` // including the first attempt
+	Methods     []string `json:"methods"`     // idempotent methods by default
+	StatusCodes []int    `json:"statusCodes"`
+	Errors      []string `json:"errors"` // connect-failure, timeout and reset
+	BufferLimit int64    `json:"bufferLimit"`
+	BackoffBase int      `json:"backoffBase"`
+	BackoffMax  int      `json:"backoffMax"`
+	TimeBudget  int      `json:"timeBudget"`
+
+	Budget     RetryBudget `json:"budget"`     // shared by all requests
+	PoolBudget RetryBudget `json:"poolBudget"` // per server pool
 }
 
 type Algorithm struct {
 	Name   string         `json:"name"`
-	Params map[string]any `json:"-,"`
+	Params map[string]any `json:"params"`
 }
 
 type Checker struct {
-	Name   string         `json:"name"`
-	Params map[string]any `json:"-,"`
+	Name    string         `json:"name"`
+	Rise    int            `json:"rise"`    // consecutive successful checks which bring a dead node up, default 1
+	Fall    int            `json:"fall"`    // consecutive failed checks which take an alive node down, default 1
+	History int            `json:"history"` // check results kept per node, default 10
+	Params  map[string]any `json:"params"`
+}
+
+// ProxyProtocol configures accepting PROXY protocol headers on the listener
+type ProxyProtocol struct {
+	Enabled      bool     `json:"enabled"`
+	TrustedCIDRs []string `json:"trustedCIDRs"` // only these sources may send a header
+	Timeout      int      `json:"timeout"`      // header read timeout in milliseconds
+}
+
+// NodeHealthCheck overrides the passive health check of a node
+type NodeHealthCheck struct {
+	URL     string   `json:"url"`     // check target like "http://10.0.0.1:9000", node URL by default
+	Port    int      `json:"port"`    // overrides the port of the target
+	Path    string   `json:"path"`    // overrides the "path" param of the checker
+	Checker *Checker `json:"checker"` // overrides the checker name and params
+}
+
+// Node is a backend server. It can be written either as a URL string or as an object.
+type Node struct {
+	URL           string           `json:"url"`
+	ProxyProtocol int              `json:"proxyProtocol"` // PROXY protocol version sent to the node, 0 for none
+	Weight        int              `json:"weight"`        // used by weighted algorithms, default 1
+	HealthCheck   *NodeHealthCheck `json:"healthCheck"`
+}
+
+func (n *Node) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &n.URL); err == nil {
+		return nil
+	}
+	type plain Node // without UnmarshalJSON
+	return json.Unmarshal(b, (*plain)(n))
+}
+
+// Admin configures the admin HTTP API, served on its own listener
+type Admin struct {
+	Port  int    `json:"port"`  // 0 disables the admin API
+	Host  string `json:"host"`  // listening host, "localhost" by default
+	Token string `json:"token"` // optional, required as "Authorization: Bearer <token>"
+}
+
+// Shutdown configures graceful shutdown on SIGINT and SIGTERM
+type Shutdown struct {
+	Lameduck     Duration `json:"lameduck"`     // readiness reports not ready for this long before the listener closes
+	DrainTimeout Duration `json:"drainTimeout"` // bound of finishing in-flight requests, 0 for unlimited
 }
 
 type Config struct {
-	Port        int         `json:"port"`
-	Nodes       []string    `json:"nodes"`
-	HealthCheck HealthCheck `json:"healthCheck"`
-	Algorithm   Algorithm   `json:"algorithm"`
-	Checker     Checker     `json:"checker"`
+	Port           int           `json:"port"`
+	Admin          Admin         `json:"admin"`
+	Shutdown       Shutdown      `json:"shutdown"`
+	ProxyProtocol  ProxyProtocol `json:"proxyProtocol"`
+	TrustedProxies []string      `json:"trustedProxies"` // CIDRs allowed to set X-Forwarded-For and Forwarded
+	Nodes          []Node        `json:"nodes"`
+	HealthCheck    HealthCheck   `json:"healthCheck"`
+	SlowStart      SlowStart     `json:"slowStart"`
+	Retry          Retry         `json:"retry"`
+	Algorithm      Algorithm     `json:"algorithm"`
+	Checker        Checker       `json:"checker"`
 }
 
+// config files of a configs directory, one of them is used
+var configFiles = []string{"config.json", "config.yaml", "config.yml", "config.toml"}
+
+// params files of a configs directory, which override the params in the config file
+const (
+	algorithmFile = "algorithm.json"
+	checkerFile   = "checker.json"
+)
+
+// New reads the config of cfgPath, which is either a config file or a configs directory, and validates it. The format
+// of a config file is JSON, YAML or TOML by its extension. Algorithm and checker params are written inline as
+// "params", or in algorithm.json and checker.json of a configs directory.
 func New(cfgPath string) (*Config, error) {
-	var config Config
-	var (
-		configPath    = filepath.Join(cfgPath, "config.json")
-		algorithmPath = filepath.Join(cfgPath, "algorithm.json")
-		checkerPath   = filepath.Join(cfgPath, "checker.json")
-	)
+	config, err := read(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
 
-	// read config.json file
-	configBytes, err := os.ReadFile(configPath)
+// read reads the config of cfgPath without validating it
+func read(cfgPath string) (*Config, error) {
+	info, err := os.Stat(cfgPath)
 	if err != nil {
 		return nil, fmt.Errorf("cannot read config file: %s", err.Error())
 	}
-	err = json.Unmarshal(configBytes, &config)
+	configPath := cfgPath
+	if info.IsDir() {
+		if configPath, err = findConfigFile(cfgPath); err != nil {
+			return nil, err
+		}
+	}
+
+	// read config file
+	var config Config
+	configBytes, err := os.ReadFile(configPath)
 	if err != nil {
+		return nil, fmt.Errorf("cannot read config file: %s", err.Error())
+	}
+	if err := unmarshal(configPath, configBytes, &config); err != nil {
 		return nil, fmt.Errorf("cannot unmarshal config file: %s", err.Error())
 	}
+	if !info.IsDir() {
+		return &config, nil
+	}
 
 	// read algorithm.json file
-	params, err := readConfigFile(algorithmPath, "algorithm")
+	params, err := readConfigFile(filepath.Join(cfgPath, algorithmFile), "algorithm")
 	if err != nil {
 		return nil, err
 	}
-	config.Algorithm.Params = params
+	if params != nil {
+		config.Algorithm.Params = params
+	}
 
 	// read checker.json file
-	params, err = readConfigFile(checkerPath, "checker")
+	params, err = readConfigFile(filepath.Join(cfgPath, checkerFile), "checker")
 	if err != nil {
 		return nil, err
 	}
-	config.Checker.Params = params
+	if params != nil {
+		config.Checker.Params = params
+	}
 
 	return &config, nil
 }
 
+// findConfigFile returns the config file of a configs directory
+func findConfigFile(dir string) (string, error) {
+	var found []string
+	for _, name := range configFiles {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			found = append(found, name)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("cannot read config file: none of %s in %s", strings.Join(configFiles, ", "), dir)
+	case 1:
+		return filepath.Join(dir, found[0]), nil
+	default:
+		return "", fmt.Errorf("cannot read config file: more than one of %s in %s", strings.Join(found, ", "), dir)
+	}
+}
+
+// unmarshal decodes a config file by the format of its extension. YAML and TOML are converted to JSON, so all formats
+// are decoded the same way. Unknown keys and values of wrong types are errors naming their key path.
+func unmarshal(path string, data []byte, config *Config) error {
+	var doc any
+	var err error
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		err = json.Unmarshal(data, &doc)
+	case ".yaml", ".yml":
+		doc, err = parseYAML(data)
+	case ".toml":
+		doc, err = parseTOML(data)
+	default:
+		return fmt.Errorf("unknown config format %q, want .json, .yaml, .yml or .toml", ext)
+	}
+	if err != nil {
+		return err
+	}
+	if _, ok := doc.(map[string]any); !ok && doc != nil {
+		return errors.New("config must be a mapping of keys")
+	}
+	if err := checkDoc(doc, reflect.TypeOf(Config{}), ""); err != nil {
+		return err
+	}
+	data, err = json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, config)
+}
+
+// readConfigFile reads a params file, nil if it doesn't exist
 func readConfigFile(path string, configType string) (map[string]any, error) {
 	var algorithmParams map[string]any
 	algorithmBytes, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("cannot read %s file: %s", configType, err.Error())
 	}
@@ -0,0 +1,103 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestNewFormats(t *testing.T) {
+	yaml, err := New(filepath.Join("examples", "config.yaml"))
+	if err != nil {
+		t.Fatalf("New(config.yaml) error = %s, want no error", err)
+	}
+	toml, err := New(filepath.Join("examples", "config.toml"))
+	if err != nil {
+		t.Fatalf("New(config.toml) error = %s, want no error", err)
+	}
+	if !reflect.DeepEqual(yaml, toml) {
+		t.Errorf("New(config.yaml) = %+v, New(config.toml) = %+v, want equal", yaml, toml)
+	}
+	json, err := New(".")
+	if err != nil {
+		t.Fatalf("New(configs directory) error = %s, want no error", err)
+	}
+
+	if yaml.Port != json.Port || yaml.HealthCheck.Passive.Period != json.HealthCheck.Passive.Period ||
+		!reflect.DeepEqual(yaml.Retry, json.Retry) || !reflect.DeepEqual(yaml.Algorithm, json.Algorithm) {
+		t.Errorf("New(config.yaml) = %+v, want the same settings as New(configs directory) = %+v", yaml, json)
+	}
+	if n := yaml.Nodes[3]; n.URL != "http://localhost:8004" || n.Weight != 2 {
+		t.Errorf("New(config.yaml) node = %+v, want http://localhost:8004 with weight 2", n)
+	}
+}
+
+func TestNewDirectory(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": "port: 8000\nnodes: [http://localhost:8001]\nalgorithm:\n  name: ch\n  params: {replicas: 3}\n" +
+			"checker:\n  name: http\n  params: {path: /health}\nhealthCheck:\n  passive:\n    period: 500ms\n",
+		"algorithm.json": `{"replicas": 2}`,
+	})
+	cfg, err := New(dir)
+	if err != nil {
+		t.Fatalf("New() error = %s, want no error", err)
+	}
+	if cfg.Port != 8000 || cfg.HealthCheck.Passive.Period.Std() != 500*time.Millisecond {
+		t.Errorf("New() = %+v, want port 8000 and period 500ms", cfg)
+	}
+	if cfg.Algorithm.Params["replicas"] != 2.0 {
+		t.Errorf("New() algorithm params = %v, want algorithm.json to override inline params", cfg.Algorithm.Params)
+	}
+	if cfg.Checker.Params["path"] != "/health" {
+		t.Errorf("New() checker params = %v, want inline params without checker.json", cfg.Checker.Params)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		path func(t *testing.T) string
+	}{
+		{"Missing", func(t *testing.T) string { return filepath.Join(t.TempDir(), "config.yaml") }},
+		{"NoConfigFile", func(t *testing.T) string { return t.TempDir() }},
+		{"TwoConfigFiles", func(t *testing.T) string {
+			return writeFiles(t, map[string]string{"config.json": `{}`, "config.toml": ``})
+		}},
+		{"Extension", func(t *testing.T) string {
+			return filepath.Join(writeFiles(t, map[string]string{"config.ini": `port=8000`}), "config.ini")
+		}},
+		{"Syntax", func(t *testing.T) string {
+			return filepath.Join(writeFiles(t, map[string]string{"lb.toml": `port = `}), "lb.toml")
+		}},
+		{"Type", func(t *testing.T) string {
+			return filepath.Join(writeFiles(t, map[string]string{"lb.yml": "port: eighty\n"}), "lb.yml")
+		}},
+		{"NotAMapping", func(t *testing.T) string {
+			return filepath.Join(writeFiles(t, map[string]string{"lb.yaml": "- 8000\n"}), "lb.yaml")
+		}},
+		{"ParamsFile", func(t *testing.T) string {
+			return writeFiles(t, map[string]string{"config.json": `{}`, "checker.json": `[`})
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := test.path(t)
+			if cfg, err := New(path); err == nil {
+				t.Errorf("New(%s) = %+v, want error", path, cfg)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package configs
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is written either as a duration string like "500ms" or "1m30s", or as a number of seconds
+type Duration time.Duration
+
+// Seconds returns a duration of n seconds
+func Seconds(n int) Duration {
+	return Duration(time.Duration(n) * time.Second)
+}
+
+// Std returns d as a time.Duration
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch value := v.(type) {
+	case float64:
+		*d = Duration(value * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration: %s", value)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration: %s", data)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package configs
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDurationUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{`20`, 20 * time.Second, false},
+		{`0.5`, 500 * time.Millisecond, false},
+		{`"500ms"`, 500 * time.Millisecond, false},
+		{`"1m30s"`, 90 * time.Second, false},
+		{`"20"`, 0, true},
+		{`true`, 0, true},
+	}
+	for _, test := range tests {
+		var d Duration
+		err := json.Unmarshal([]byte(test.data), &d)
+		if (err != nil) != test.wantErr || d.Std() != test.want {
+			t.Errorf("Duration.UnmarshalJSON(%s) = %s %v, want %s, error %t", test.data, d, err, test.want, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package configs
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// EnvPrefix starts the names of environment variables which override config keys
+const EnvPrefix = "LB_"
+
+// key is a config key which can be overridden, like healthCheck.passive.period
+type key struct {
+	path  []string // json names from Config down to the field
+	index []int    // field indexes from Config down to the field
+	typ   reflect.Type
+}
+
+// name returns the name of the key, which is also its command line flag, like "healthCheck.passive.period"
+func (k key) name() string {
+	return strings.Join(k.path, ".")
+}
+
+// env returns the environment variable of the key, like LB_HEALTHCHECK_PASSIVE_PERIOD
+func (k key) env() string {
+	return EnvPrefix + strings.ToUpper(strings.Join(k.path, "_"))
+}
+
+// kind describes the type of values of the key for usage messages
+func (k key) kind() string {
+	switch {
+	case k.typ == reflect.TypeOf(Duration(0)):
+		return "duration"
+	case k.typ.Kind() == reflect.Slice:
+		return "list"
+	case k.typ.Kind() == reflect.Map:
+		return "object"
+	}
+	return k.typ.Kind().String()
+}
+
+// set decodes s into the field of the key in config
+func (k key) set(config *Config, s string) error {
+	if err := decode(reflect.ValueOf(config).Elem().FieldByIndex(k.index), s); err != nil {
+		return fmt.Errorf("invalid %s %q: %s", k.name(), s, err.Error())
+	}
+	return nil
+}
+
+// keys returns every key of Config. Structs are walked down to their fields, other fields like slices and maps are
+// keys as a whole.
+func keys() []key {
+	var all []key
+	var walk func(t reflect.Type, path []string, index []int)
+	walk = func(t reflect.Type, path []string, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			k := key{
+				path:  append(append([]string{}, path...), name),
+				index: append(append([]int{}, index...), i),
+				typ:   f.Type,
+			}
+			if f.Type.Kind() == reflect.Struct {
+				walk(f.Type, k.path, k.index)
+				continue
+			}
+			all = append(all, k)
+		}
+	}
+	walk(reflect.TypeOf(Config{}), nil, nil)
+	return all
+}
+
+// decode sets v from s, which is written like a JSON value. Quotes of strings may be left out, and slices may be
+// written as comma separated values like "GET,HEAD" instead of a JSON array.
+func decode(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Slice && !strings.HasPrefix(strings.TrimSpace(s), "[") {
+		items := reflect.MakeSlice(v.Type(), 0, 0)
+		if strings.TrimSpace(s) != "" {
+			for _, part := range strings.Split(s, ",") {
+				item := reflect.New(v.Type().Elem()).Elem()
+				if err := decode(item, strings.TrimSpace(part)); err != nil {
+					return err
+				}
+				items = reflect.Append(items, item)
+			}
+		}
+		v.Set(items)
+		return nil
+	}
+
+	value := reflect.New(v.Type())
+	if err := json.Unmarshal([]byte(s), value.Interface()); err == nil {
+		v.Set(value.Elem())
+		return nil
+	}
+	// a string without quotes
+	value = reflect.New(v.Type())
+	quoted, _ := json.Marshal(s)
+	err := json.Unmarshal(quoted, value.Interface())
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Errorf("want %s", v.Type())
+	}
+	if err != nil {
+		return err
+	}
+	v.Set(value.Elem())
+	return nil
+}
+
+// ApplyEnv overrides the keys of config by environment variables in environ, which is formatted like os.Environ.
+// Variables are named by EnvPrefix and the key path in upper case, like LB_PORT or LB_HEALTHCHECK_PASSIVE_PERIOD.
+// Other variables are ignored.
+func ApplyEnv(config *Config, environ []string) error {
+	vars := make(map[string]string)
+	for _, kv := range environ {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			vars[name] = value
+		}
+	}
+	for _, k := range keys() {
+		if value, ok := vars[k.env()]; ok {
+			if err := k.set(config, value); err != nil {
+				return fmt.Errorf("%s: %s", k.env(), err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// Flags are command line flags which override the keys of a config, named by the key path like -port or
+// -healthCheck.passive.period
+type Flags struct {
+	values []flagValue // in the order they are set
+}
+
+// NewFlags defines a flag in fs for every config key
+func NewFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	for _, k := range keys() {
+		usage := fmt.Sprintf("config key %s of type `%s` (env %s)", k.name(), k.kind(), k.env())
+		fs.Var(&keyFlag{key: k, flags: f}, k.name(), usage)
+	}
+	return f
+}
+
+// Apply overrides the keys of config by the flags which are set
+func (f *Flags) Apply(config *Config) error {
+	if f == nil {
+		return nil
+	}
+	for _, v := range f.values {
+		if err := v.key.set(config, v.value); err != nil {
+			return fmt.Errorf("-%s: %s", v.key.name(), err.Error())
+		}
+	}
+	return nil
+}
+
+type flagValue struct {
+	key   key
+	value string
+}
+
+// keyFlag is the flag.Value of a config key
+type keyFlag struct {
+	key   key
+	flags *Flags
+	value string
+}
+
+func (f *keyFlag) String() string {
+	return f.value
+}
+
+// Set records the value, which is checked by decoding it into an empty config and applied by Flags.Apply
+func (f *keyFlag) Set(s string) error {
+	if err := f.key.set(&Config{}, s); err != nil {
+		return err
+	}
+	f.value = s
+	f.flags.values = append(f.flags.values, flagValue{key: f.key, value: s})
+	return nil
+}
+
+// IsBoolFlag lets bool keys be set without a value, like -proxyProtocol.enabled
+func (f *keyFlag) IsBoolFlag() bool {
+	return f.key.typ.Kind() == reflect.Bool
+}
+
+// Load reads the config of cfgPath like New and overrides it by environment variables and flags, in this order, before
+// validating it. The config file isn't read if cfgPath is empty, so a config can be made of environment variables and
+// flags only.
+func Load(cfgPath string, flags *Flags) (*Config, error) {
+	config := &Config{}
+	if cfgPath != "" {
+		var err error
+		if config, err = read(cfgPath); err != nil {
+			return nil, err
+		}
+	}
+	if err := ApplyEnv(config, os.Environ()); err != nil {
+		return nil, err
+	}
+	if err := flags.Apply(config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
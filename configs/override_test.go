@@ -0,0 +1,125 @@
+package configs
+
+import (
+	"flag"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestApplyEnv(t *testing.T) {
+	cfg := &Config{Port: 8000, Retry: Retry{Methods: []string{"GET"}}}
+	err := ApplyEnv(cfg, []string{
+		"LB_PORT=9000",
+		"LB_NODES=http://localhost:8001, http://localhost:8002",
+		"LB_HEALTHCHECK_PASSIVE_PERIOD=500ms",
+		"LB_HEALTHCHECK_PASSIVE_TIMEOUT=2",
+		"LB_HEALTHCHECK_OUTLIERDETECTION_ENABLED=true",
+		"LB_RETRY_METHODS=",
+		"LB_RETRY_STATUSCODES=[502, 503]",
+		"LB_ADMIN_TOKEN=1234",
+		"LB_ALGORITHM_PARAMS={\"replicas\": 2}",
+		"LB_LISTENER_FD=3",
+		"PATH=/bin",
+	})
+	if err != nil {
+		t.Fatalf("ApplyEnv() error = %s, want no error", err)
+	}
+	want := &Config{
+		Port:  9000,
+		Nodes: []Node{{URL: "http://localhost:8001"}, {URL: "http://localhost:8002"}},
+		HealthCheck: HealthCheck{
+			Passive:          PassiveHealthCheck{Period: Duration(500 * time.Millisecond), Timeout: Seconds(2)},
+			OutlierDetection: OutlierDetection{Enabled: true},
+		},
+		Retry:     Retry{Methods: []string{}, StatusCodes: []int{502, 503}},
+		Admin:     Admin{Token: "1234"},
+		Algorithm: Algorithm{Params: map[string]any{"replicas": 2.0}},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("ApplyEnv() = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestApplyEnvInvalid(t *testing.T) {
+	for _, kv := range []string{
+		"LB_PORT=eighty",
+		"LB_HEALTHCHECK_PASSIVE_PERIOD=soon",
+		"LB_RETRY_STATUSCODES=502,bad",
+		"LB_PROXYPROTOCOL_ENABLED=yes",
+		"LB_CHECKER_PARAMS=[1]",
+	} {
+		if err := ApplyEnv(&Config{}, []string{kv}); err == nil {
+			t.Errorf("ApplyEnv(%s) = nil, want error", kv)
+		}
+	}
+}
+
+func TestFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	flags := NewFlags(fs)
+	err := fs.Parse([]string{"-port", "9000", "-proxyProtocol.enabled", "-nodes", "http://localhost:8001",
+		"-healthCheck.passive.period=5s", "-port=9001"})
+	if err != nil {
+		t.Fatalf("FlagSet.Parse() error = %s, want no error", err)
+	}
+	cfg := &Config{Port: 8000, Checker: Checker{Name: "tcp"}}
+	if err := flags.Apply(cfg); err != nil {
+		t.Fatalf("Flags.Apply() error = %s, want no error", err)
+	}
+	want := &Config{
+		Port:          9001,
+		ProxyProtocol: ProxyProtocol{Enabled: true},
+		Nodes:         []Node{{URL: "http://localhost:8001"}},
+		HealthCheck:   HealthCheck{Passive: PassiveHealthCheck{Period: Seconds(5)}},
+		Checker:       Checker{Name: "tcp"},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Flags.Apply() = %+v, want %+v", cfg, want)
+	}
+
+	if err := fs.Parse([]string{"-port", "eighty"}); err == nil {
+		t.Errorf("FlagSet.Parse(-port eighty) = nil, want error")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := NewFlags(fs)
+	if err := fs.Parse([]string{"-admin.port", "9090"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LB_PORT", "9000")
+	t.Setenv("LB_ADMIN_PORT", "9091")
+	t.Setenv("LB_ALGORITHM_NAME", "rr")
+
+	// flags > env > file
+	cfg, err := Load(filepath.Join("examples", "config.yaml"), flags)
+	if err != nil {
+		t.Fatalf("Load() error = %s, want no error", err)
+	}
+	if cfg.Admin.Port != 9090 || cfg.Port != 9000 || cfg.Algorithm.Name != "rr" || cfg.Checker.Name != "tcp" {
+		t.Errorf("Load() = %+v, want admin port of the flag, port and algorithm of env, checker of the file", cfg)
+	}
+
+	// without a config file
+	t.Setenv("LB_NODES", "http://localhost:8001")
+	t.Setenv("LB_HEALTHCHECK_PASSIVE_PERIOD", "5s")
+	t.Setenv("LB_CHECKER_NAME", "tcp")
+	cfg, err = Load("", nil)
+	if err != nil {
+		t.Fatalf("Load(no file) error = %s, want no error", err)
+	}
+	if cfg.Port != 9000 || cfg.Admin.Port != 9091 || len(cfg.Nodes) != 1 {
+		t.Errorf("Load(no file) = %+v, want the config of env only", cfg)
+	}
+
+	// validated after the overrides
+	t.Setenv("LB_PORT", "0")
+	if _, err := Load("", nil); err == nil {
+		t.Errorf("Load(LB_PORT=0) = nil, want error")
+	}
+}
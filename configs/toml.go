@@ -0,0 +1,425 @@
+package configs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML parses the TOML subset used by config files into maps, slices, strings, float64 numbers and bools, like
+// encoding/json does. It supports tables, arrays of tables, dotted keys, basic and literal strings including
+// multi-line ones, numbers, bools, arrays and inline tables. Dates and times aren't supported.
+func parseTOML(data []byte) (map[string]any, error) {
+	p := &tomlParser{s: string(data), line: 1}
+	root := make(map[string]any)
+	current := root
+	defined := make(map[string]bool) // explicitly defined tables
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return root, nil
+		}
+		var err error
+		if p.peek() == '[' {
+			current, err = p.parseHeader(root, defined)
+		} else {
+			err = p.parseKeyValue(current)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := p.endOfLine(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+type tomlParser struct {
+	s    string
+	pos  int
+	line int
+}
+
+func (p *tomlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("toml: line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *tomlParser) next() byte {
+	c := p.s[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+// skipSpaces skips spaces and tabs
+func (p *tomlParser) skipSpaces() {
+	for c := p.peek(); c == ' ' || c == '\t'; c = p.peek() {
+		p.next()
+	}
+}
+
+// skipBlank skips whitespace, newlines and comments
+func (p *tomlParser) skipBlank() {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t', '\r', '\n':
+			p.next()
+		case '#':
+			p.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) skipComment() {
+	for !p.eof() && p.peek() != '\n' {
+		p.next()
+	}
+}
+
+// endOfLine expects only a comment until the end of the line
+func (p *tomlParser) endOfLine() error {
+	p.skipSpaces()
+	if p.peek() == '#' {
+		p.skipComment()
+	}
+	if p.peek() == '\r' {
+		p.next()
+	}
+	if !p.eof() && p.peek() != '\n' {
+		return p.errorf("unexpected %q, want a new line", p.peek())
+	}
+	return nil
+}
+
+// parseHeader parses [table] or [[array of tables]] and returns the table which following keys belong to
+func (p *tomlParser) parseHeader(root map[string]any, defined map[string]bool) (map[string]any, error) {
+	p.next() // [
+	array := p.peek() == '['
+	if array {
+		p.next()
+	}
+	p.skipSpaces()
+	path, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.s[p.pos:], closing) {
+		return nil, p.errorf("want %q after table name", closing)
+	}
+	p.pos += len(closing)
+
+	parent, err := p.table(root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	name := path[len(path)-1]
+	if array {
+		tables, ok := parent[name].([]any)
+		if _, exists := parent[name]; exists && !ok {
+			return nil, p.errorf("%s is not an array of tables", strings.Join(path, "."))
+		}
+		t := make(map[string]any)
+		parent[name] = append(tables, t)
+		return t, nil
+	}
+	key := strings.Join(path, ".")
+	if defined[key] {
+		return nil, p.errorf("table %s is defined twice", key)
+	}
+	defined[key] = true
+	return p.table(parent, []string{name})
+}
+
+// table returns the table at path under t, creating missing tables. The last table of an array of tables is used.
+func (p *tomlParser) table(t map[string]any, path []string) (map[string]any, error) {
+	for _, name := range path {
+		switch v := t[name].(type) {
+		case nil:
+			child := make(map[string]any)
+			t[name] = child
+			t = child
+		case map[string]any:
+			t = v
+		case []any:
+			var child map[string]any
+			if len(v) > 0 {
+				child, _ = v[len(v)-1].(map[string]any)
+			}
+			if child == nil {
+				return nil, p.errorf("%s is not a table", name)
+			}
+			t = child
+		default:
+			return nil, p.errorf("%s is not a table", name)
+		}
+	}
+	return t, nil
+}
+
+func (p *tomlParser) parseKeyValue(t map[string]any) error {
+	path, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	p.skipSpaces()
+	if p.peek() != '=' {
+		return p.errorf("want '=' after key %s", strings.Join(path, "."))
+	}
+	p.next()
+	p.skipSpaces()
+	v, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+	parent, err := p.table(t, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	name := path[len(path)-1]
+	if _, dup := parent[name]; dup {
+		return p.errorf("duplicate key %s", strings.Join(path, "."))
+	}
+	parent[name] = v
+	return nil
+}
+
+// parseKey parses a key made of bare or quoted parts separated by dots
+func (p *tomlParser) parseKey() ([]string, error) {
+	var path []string
+	for {
+		p.skipSpaces()
+		var part string
+		switch c := p.peek(); {
+		case c == '"' || c == '\'':
+			s, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			part = s
+		default:
+			start := p.pos
+			for c := p.peek(); c == '_' || c == '-' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+				(c >= '0' && c <= '9'); c = p.peek() {
+				p.next()
+			}
+			if p.pos == start {
+				return nil, p.errorf("invalid key")
+			}
+			part = p.s[start:p.pos]
+		}
+		path = append(path, part)
+		p.skipSpaces()
+		if p.peek() != '.' {
+			return path, nil
+		}
+		p.next()
+	}
+}
+
+func (p *tomlParser) parseValue() (any, error) {
+	switch c := p.peek(); {
+	case c == '"' || c == '\'':
+		return p.parseString()
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return p.parseInlineTable()
+	case strings.HasPrefix(p.s[p.pos:], "true"):
+		p.pos += len("true")
+		return true, nil
+	case strings.HasPrefix(p.s[p.pos:], "false"):
+		p.pos += len("false")
+		return false, nil
+	case c == 0 || c == '\n' || c == '#':
+		return nil, p.errorf("missing value")
+	}
+	return p.parseNumber()
+}
+
+func (p *tomlParser) parseNumber() (float64, error) {
+	start := p.pos
+	for c := p.peek(); c != 0 && strings.IndexByte(" \t\r\n,]}#", c) < 0; c = p.peek() {
+		p.next()
+	}
+	s := p.s[start:p.pos]
+	switch s {
+	case "inf", "+inf", "-inf", "nan", "+nan", "-nan":
+		return 0, p.errorf("unsupported number %s", s)
+	}
+	if strings.ContainsAny(s, ":") || (len(s) > 4 && s[4] == '-') {
+		return 0, p.errorf("dates and times are not supported: %s", s)
+	}
+	clean := strings.ReplaceAll(s, "_", "")
+	if n, err := strconv.ParseInt(clean, 10, 64); err == nil {
+		return float64(n), nil
+	}
+	if strings.HasPrefix(clean, "0x") || strings.HasPrefix(clean, "0o") || strings.HasPrefix(clean, "0b") {
+		if n, err := strconv.ParseInt(clean, 0, 64); err == nil {
+			return float64(n), nil
+		}
+	}
+	if n, err := strconv.ParseFloat(clean, 64); err == nil && !strings.ContainsAny(clean, "xXpPiInN") {
+		return n, nil
+	}
+	return 0, p.errorf("invalid value %s", s)
+}
+
+// parseString parses basic "..." and literal '...' strings, and their multi-line forms
+func (p *tomlParser) parseString() (string, error) {
+	q := p.peek()
+	multiline := strings.HasPrefix(p.s[p.pos:], strings.Repeat(string(q), 3))
+	if multiline {
+		p.pos += 3
+		// a new line right after the opening delimiter is trimmed
+		if strings.HasPrefix(p.s[p.pos:], "\r\n") {
+			p.pos++
+		}
+		if p.peek() == '\n' {
+			p.next()
+		}
+	} else {
+		p.next()
+	}
+
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		if multiline && strings.HasPrefix(p.s[p.pos:], strings.Repeat(string(q), 3)) {
+			p.pos += 3
+			return b.String(), nil
+		}
+		c := p.next()
+		switch {
+		case !multiline && c == q:
+			return b.String(), nil
+		case !multiline && c == '\n':
+			return "", p.errorf("unterminated string")
+		case q == '"' && c == '\\':
+			if err := p.parseEscape(&b, multiline); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+func (p *tomlParser) parseEscape(b *strings.Builder, multiline bool) error {
+	if p.eof() {
+		return p.errorf("unterminated string")
+	}
+	c := p.next()
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case '"', '\\':
+		b.WriteByte(c)
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if p.pos+size > len(p.s) {
+			return p.errorf("invalid unicode escape")
+		}
+		r, err := strconv.ParseUint(p.s[p.pos:p.pos+size], 16, 32)
+		if err != nil {
+			return p.errorf("invalid unicode escape")
+		}
+		p.pos += size
+		b.WriteRune(rune(r))
+	case ' ', '\t', '\r', '\n':
+		// a line ending backslash trims the following whitespace
+		if !multiline {
+			return p.errorf("invalid escape")
+		}
+		for c := p.peek(); c == ' ' || c == '\t' || c == '\r' || c == '\n'; c = p.peek() {
+			p.next()
+		}
+	default:
+		return p.errorf("invalid escape \\%c", c)
+	}
+	return nil
+}
+
+// parseArray parses an array, which may span lines and hold comments
+func (p *tomlParser) parseArray() ([]any, error) {
+	p.next() // [
+	items := make([]any, 0)
+	for {
+		p.skipBlank()
+		if p.peek() == ']' {
+			p.next()
+			return items, nil
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+		p.skipBlank()
+		switch p.peek() {
+		case ',':
+			p.next()
+		case ']':
+		default:
+			return nil, p.errorf("want ',' or ']' in array")
+		}
+	}
+}
+
+// parseInlineTable parses a table like {a = 1, b.c = "x"} written on a single line
+func (p *tomlParser) parseInlineTable() (map[string]any, error) {
+	p.next() // {
+	t := make(map[string]any)
+	p.skipSpaces()
+	if p.peek() == '}' {
+		p.next()
+		return t, nil
+	}
+	for {
+		if err := p.parseKeyValue(t); err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		switch p.peek() {
+		case ',':
+			p.next()
+		case '}':
+			p.next()
+			return t, nil
+		default:
+			return nil, p.errorf("want ',' or '}' in inline table")
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package configs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want map[string]any
+	}{
+		{"Empty", "# only a comment\n", map[string]any{}},
+		{"Values", `
+port = 8_000
+ratio = 0.5
+hex = 0xff
+enabled = true
+name = "a # b\t\u00e9" # comment
+literal = 'C:\path'
+multiline = """
+line 1
+line 2"""
+`, map[string]any{
+			"port": 8000.0, "ratio": 0.5, "hex": 255.0, "enabled": true, "name": "a # b\té", "literal": `C:\path`,
+			"multiline": "line 1\nline 2",
+		}},
+		{"Tables", `
+[healthCheck.passive]
+period = "20s"
+
+[algorithm]
+name = "ch"
+params = { replicas = 2, hashFunc = "crc32" }
+
+[checker.params]
+"status.code" = 200
+`, map[string]any{
+			"healthCheck": map[string]any{"passive": map[string]any{"period": "20s"}},
+			"algorithm":   map[string]any{"name": "ch", "params": map[string]any{"replicas": 2.0, "hashFunc": "crc32"}},
+			"checker":     map[string]any{"params": map[string]any{"status.code": 200.0}},
+		}},
+		{"Arrays", `
+statusCodes = [
+  502, # bad gateway
+  503,
+]
+nodes = ["http://localhost:8001"]
+
+[[nodes2]]
+url = "http://localhost:8002"
+weight = 2
+
+[[nodes2]]
+url = "http://localhost:8003"
+`, map[string]any{
+			"statusCodes": []any{502.0, 503.0},
+			"nodes":       []any{"http://localhost:8001"},
+			"nodes2": []any{
+				map[string]any{"url": "http://localhost:8002", "weight": 2.0},
+				map[string]any{"url": "http://localhost:8003"},
+			},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseTOML([]byte(test.data))
+			if err != nil {
+				t.Fatalf("parseTOML() error = %s, want no error", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseTOML() = %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseTOMLInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"DuplicateKey", "port = 1\nport = 2\n"},
+		{"DuplicateTable", "[a]\n[a]\n"},
+		{"NotATable", "a = 1\n[a.b]\n"},
+		{"MissingValue", "port =\n"},
+		{"TwoValues", "port = 1 2\n"},
+		{"Unterminated", "name = \"text\n"},
+		{"Date", "at = 2024-01-01\n"},
+		{"Escape", "name = \"\\q\"\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if v, err := parseTOML([]byte(test.data)); err == nil {
+				t.Errorf("parseTOML(%q) = %#v, want error", test.data, v)
+			}
+		})
+	}
+}
@@ -0,0 +1,450 @@
+package configs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/samanazadi/load-balancer/internal/netutil"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaults applied by Validate to keys which aren't set. Packages which create their parts from a config fall back
+// to them too.
+const (
+	DefaultAdminHost                 = "localhost"
+	DefaultWeight                    = 1
+	DefaultPassiveTimeout            = Duration(5 * time.Second)
+	DefaultInitialState              = "up"
+	DefaultBreakerWindow             = 10 // seconds
+	DefaultBreakerMinRequests        = 10
+	DefaultBreakerOpenDuration       = 5000 // ms
+	DefaultBreakerHalfOpenRequests   = 1
+	DefaultOutlierInterval           = 10000  // ms
+	DefaultOutlierBaseEjectionTime   = 30000  // ms
+	DefaultOutlierMaxEjectionTime    = 300000 // ms
+	DefaultOutlierMaxEjectionPercent = 10
+	DefaultOutlierMinHosts           = 5
+	DefaultOutlierRequestVolume      = 100
+	DefaultRise                      = 1
+	DefaultFall                      = 1
+	DefaultHistory                   = 10
+	DefaultSlowStartMode             = "linear"
+	DefaultSlowStartMinWeight        = 0.1
+	DefaultMaxAttempts               = 3
+	DefaultBufferLimit               = 64 * 1024
+	DefaultBackoffBase               = 25  // ms
+	DefaultBackoffMax                = 250 // ms
+)
+
+var (
+	DefaultRetryMethods = []string{"GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE"}
+	DefaultRetryErrors  = []string{"connect-failure", "timeout", "reset"}
+)
+
+// FieldError is an invalid value of a config key, like "healthCheck.passive.period: must be positive"
+type FieldError struct {
+	Path    string // key path like nodes[1].url
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// checkDoc checks a decoded config document against the type t, rejecting unknown keys and values of wrong types
+func checkDoc(doc any, t reflect.Type, path string) error {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch v := doc.(type) {
+	case map[string]any:
+		if t.Kind() != reflect.Struct {
+			break
+		}
+		fields := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name != "" && name != "-" {
+				fields[name] = t.Field(i).Type
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var errs []error
+		for _, name := range names {
+			ft, ok := fields[name]
+			if !ok {
+				errs = append(errs, &FieldError{Path: joinPath(path, name), Message: "unknown key"})
+				continue
+			}
+			if err := checkDoc(v[name], ft, joinPath(path, name)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	case []any:
+		if t.Kind() != reflect.Slice {
+			break
+		}
+		var errs []error
+		for i, item := range v {
+			if err := checkDoc(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	// a value decoded on its own, so its error names its key
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return &FieldError{Path: path, Message: err.Error()}
+	}
+	err = json.Unmarshal(data, reflect.New(t).Interface())
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return &FieldError{Path: path, Message: fmt.Sprintf("want %s, got %s", typeName(t), typeErr.Value)}
+	}
+	if err != nil {
+		return &FieldError{Path: path, Message: err.Error()}
+	}
+	return nil
+}
+
+// typeName describes t in the terms of config files
+func typeName(t reflect.Type) string {
+	switch {
+	case t == reflect.TypeOf(Duration(0)):
+		return "duration"
+	case t == reflect.TypeOf(Node{}):
+		return "URL or object"
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice:
+		return "list"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "integer"
+	}
+	return t.Kind().String()
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// validator collects the errors of a config
+type validator struct {
+	errs []error
+}
+
+func (v *validator) errorf(path, format string, args ...any) {
+	v.errs = append(v.errs, &FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) port(path string, port int, allowZero bool) {
+	min := 1
+	if allowZero {
+		min = 0
+	}
+	if port < min || port > 65535 {
+		v.errorf(path, "must be between %d and 65535", min)
+	}
+}
+
+func (v *validator) nonNegative(path string, n float64) {
+	if n < 0 {
+		v.errorf(path, "must not be negative")
+	}
+}
+
+func (v *validator) fraction(path string, f float64) {
+	if f < 0 || f > 1 {
+		v.errorf(path, "must be between 0 and 1")
+	}
+}
+
+func (v *validator) oneOf(path, value string, values ...string) {
+	for _, allowed := range values {
+		if value == allowed {
+			return
+		}
+	}
+	v.errorf(path, "must be one of %s, not %q", strings.Join(values, ", "), value)
+}
+
+func (v *validator) cidrs(path string, cidrs []string) {
+	for i, c := range cidrs {
+		if _, err := netutil.ParseCIDRList([]string{c}); err != nil {
+			v.errorf(fmt.Sprintf("%s[%d]", path, i), "%s", err.Error())
+		}
+	}
+}
+
+// url checks a URL with a host like http://localhost:8001. Schemes are checked if any are given.
+func (v *validator) url(path, s string, schemes ...string) {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" || u.Hostname() == "" {
+		v.errorf(path, "invalid URL %q, want like http://host:port", s)
+		return
+	}
+	if len(schemes) > 0 && !slices.Contains(schemes, u.Scheme) {
+		v.errorf(path, "invalid URL %q, want scheme %s", s, strings.Join(schemes, " or "))
+	}
+}
+
+func (v *validator) checker(path string, c *Checker) {
+	if c.Name == "" {
+		v.errorf(path+".name", "is required")
+	}
+	v.nonNegative(path+".rise", float64(c.Rise))
+	v.nonNegative(path+".fall", float64(c.Fall))
+	v.nonNegative(path+".history", float64(c.History))
+}
+
+// node checks the settings of a node, except that its URL is unique
+func (v *validator) node(path string, n Node) {
+	v.url(joinPath(path, "url"), n.URL, "http", "https")
+	v.nonNegative(joinPath(path, "weight"), float64(n.Weight))
+	if n.ProxyProtocol < 0 || n.ProxyProtocol > 2 {
+		v.errorf(joinPath(path, "proxyProtocol"), "must be 0, 1 or 2")
+	}
+	if hc := n.HealthCheck; hc != nil {
+		if hc.URL != "" {
+			v.url(joinPath(path, "healthCheck.url"), hc.URL)
+		}
+		v.port(joinPath(path, "healthCheck.port"), hc.Port, true)
+		if hc.Checker != nil {
+			v.checker(joinPath(path, "healthCheck.checker"), hc.Checker)
+		}
+	}
+}
+
+func (v *validator) budget(path string, b RetryBudget) {
+	v.nonNegative(path+".ratio", b.Ratio)
+	v.nonNegative(path+".minPerSecond", float64(b.MinPerSecond))
+	v.nonNegative(path+".window", float64(b.Window))
+}
+
+// Validate applies the defaults of keys which aren't set and checks every value. Its error joins a FieldError for
+// every invalid value.
+func (c *Config) Validate() error {
+	c.setDefaults()
+	v := &validator{}
+
+	v.port("port", c.Port, false)
+	v.port("admin.port", c.Admin.Port, true)
+	v.nonNegative("shutdown.lameduck", float64(c.Shutdown.Lameduck))
+	v.nonNegative("shutdown.drainTimeout", float64(c.Shutdown.DrainTimeout))
+	v.cidrs("proxyProtocol.trustedCIDRs", c.ProxyProtocol.TrustedCIDRs)
+	v.nonNegative("proxyProtocol.timeout", float64(c.ProxyProtocol.Timeout))
+	v.cidrs("trustedProxies", c.TrustedProxies)
+
+	// nodes
+	if len(c.Nodes) == 0 {
+		v.errorf("nodes", "must not be empty")
+	}
+	hosts := make(map[string]bool, len(c.Nodes))
+	for i, n := range c.Nodes {
+		path := fmt.Sprintf("nodes[%d]", i)
+		if u, err := url.Parse(n.URL); err == nil && u.Host != "" {
+			if hosts[u.Host] {
+				v.errorf(path+".url", "duplicate node %s", u.Host)
+			}
+			hosts[u.Host] = true
+		}
+		v.node(path, n)
+	}
+
+	// health check
+	a := c.HealthCheck.Active
+	v.nonNegative("healthCheck.active.maxRetry", float64(a.MaxRetry))
+	v.fraction("healthCheck.active.errorRate", a.ErrorRate)
+	v.nonNegative("healthCheck.active.window", float64(a.Window))
+	v.nonNegative("healthCheck.active.minRequests", float64(a.MinRequests))
+	v.nonNegative("healthCheck.active.openDuration", float64(a.OpenDuration))
+	v.nonNegative("healthCheck.active.halfOpenRequests", float64(a.HalfOpenRequests))
+	p := c.HealthCheck.Passive
+	if p.Period <= 0 {
+		v.errorf("healthCheck.passive.period", "must be positive")
+	}
+	v.nonNegative("healthCheck.passive.timeout", float64(p.Timeout))
+	v.nonNegative("healthCheck.passive.unhealthyPeriod", float64(p.UnhealthyPeriod))
+	v.fraction("healthCheck.passive.jitter", p.Jitter)
+	v.nonNegative("healthCheck.passive.maxConcurrent", float64(p.MaxConcurrent))
+	v.oneOf("healthCheck.passive.initialState", p.InitialState, "up", "down", "unknown")
+	v.nonNegative("healthCheck.passive.startupTimeout", float64(p.StartupTimeout))
+	o := c.HealthCheck.OutlierDetection
+	v.nonNegative("healthCheck.outlierDetection.interval", float64(o.Interval))
+	v.nonNegative("healthCheck.outlierDetection.baseEjectionTime", float64(o.BaseEjectionTime))
+	v.nonNegative("healthCheck.outlierDetection.maxEjectionTime", float64(o.MaxEjectionTime))
+	v.nonNegative("healthCheck.outlierDetection.consecutive5xx", float64(o.Consecutive5xx))
+	v.nonNegative("healthCheck.outlierDetection.consecutiveGatewayFailure", float64(o.ConsecutiveGatewayFailure))
+	v.nonNegative("healthCheck.outlierDetection.minHosts", float64(o.MinHosts))
+	v.nonNegative("healthCheck.outlierDetection.requestVolume", float64(o.RequestVolume))
+	if o.MaxEjectionPercent < 0 || o.MaxEjectionPercent > 100 {
+		v.errorf("healthCheck.outlierDetection.maxEjectionPercent", "must be between 0 and 100")
+	}
+	v.nonNegative("healthCheck.outlierDetection.successRateStdevFactor", o.SuccessRateStdevFactor)
+	v.nonNegative("healthCheck.outlierDetection.latencyStdevFactor", o.LatencyStdevFactor)
+
+	// slow start
+	v.nonNegative("slowStart.window", float64(c.SlowStart.Window))
+	v.oneOf("slowStart.mode", c.SlowStart.Mode, "linear", "exponential")
+	if c.SlowStart.MinWeight <= 0 || c.SlowStart.MinWeight > 1 {
+		v.errorf("slowStart.minWeight", "must be above 0 and at most 1")
+	}
+
+	// retry
+	r := c.Retry
+	if r.MaxAttempts < 1 {
+		v.errorf("retry.maxAttempts", "must be at least 1")
+	}
+	for i, code := range r.StatusCodes {
+		if code < 100 || code > 599 {
+			v.errorf(fmt.Sprintf("retry.statusCodes[%d]", i), "invalid status code %d", code)
+		}
+	}
+	for i, e := range r.Errors {
+		v.oneOf(fmt.Sprintf("retry.errors[%d]", i), e, DefaultRetryErrors...)
+	}
+	v.nonNegative("retry.bufferLimit", float64(r.BufferLimit))
+	v.nonNegative("retry.backoffBase", float64(r.BackoffBase))
+	if r.BackoffMax < r.BackoffBase {
+		v.errorf("retry.backoffMax", "must not be less than backoffBase")
+	}
+	v.nonNegative("retry.timeBudget", float64(r.TimeBudget))
+	v.budget("retry.budget", r.Budget)
+	v.budget("retry.poolBudget", r.PoolBudget)
+
+	// algorithm and checker, whose names and params are checked by their constructors
+	if c.Algorithm.Name == "" {
+		v.errorf("algorithm.name", "is required")
+	}
+	v.checker("checker", &c.Checker)
+	return errors.Join(v.errs...)
+}
+
+// Warnings returns a message for every deprecated key which is set, like healthCheck.active.retryDelay
+func (c *Config) Warnings() []string {
+	var warnings []string
+	if c.HealthCheck.Active.RetryDelay != 0 {
+		warnings = append(warnings, "healthCheck.active.retryDelay is deprecated and ignored, "+
+			"use retry.backoffBase and retry.backoffMax")
+	}
+	return warnings
+}
+
+// Validate applies the defaults of the keys of a node which aren't set and checks every value, like Config.Validate
+// does for each of its nodes. Paths of errors are relative to the node, like "url". Checkers are checked by their
+// constructors.
+func (n *Node) Validate() error {
+	n.setDefaults()
+	v := &validator{}
+	v.node("", *n)
+	return errors.Join(v.errs...)
+}
+
+// setDefaults sets the keys of a node which aren't set to their defaults
+func (n *Node) setDefaults() {
+	if n.Weight == 0 {
+		n.Weight = DefaultWeight
+	}
+}
+
+// setDefaults sets the keys which aren't set to their defaults
+func (c *Config) setDefaults() {
+	if c.Admin.Host == "" {
+		c.Admin.Host = DefaultAdminHost
+	}
+	for i := range c.Nodes {
+		c.Nodes[i].setDefaults()
+	}
+	a := &c.HealthCheck.Active
+	if a.Window == 0 {
+		a.Window = DefaultBreakerWindow
+	}
+	if a.MinRequests == 0 {
+		a.MinRequests = DefaultBreakerMinRequests
+	}
+	if a.OpenDuration == 0 {
+		a.OpenDuration = DefaultBreakerOpenDuration
+	}
+	if a.HalfOpenRequests == 0 {
+		a.HalfOpenRequests = DefaultBreakerHalfOpenRequests
+	}
+	p := &c.HealthCheck.Passive
+	if p.Timeout == 0 {
+		p.Timeout = DefaultPassiveTimeout
+	}
+	if p.InitialState == "" {
+		p.InitialState = DefaultInitialState
+	}
+	o := &c.HealthCheck.OutlierDetection
+	if o.Interval == 0 {
+		o.Interval = DefaultOutlierInterval
+	}
+	if o.BaseEjectionTime == 0 {
+		o.BaseEjectionTime = DefaultOutlierBaseEjectionTime
+	}
+	if o.MaxEjectionTime == 0 {
+		o.MaxEjectionTime = DefaultOutlierMaxEjectionTime
+	}
+	if o.MaxEjectionPercent == 0 {
+		o.MaxEjectionPercent = DefaultOutlierMaxEjectionPercent
+	}
+	if o.MinHosts == 0 {
+		o.MinHosts = DefaultOutlierMinHosts
+	}
+	if o.RequestVolume == 0 {
+		o.RequestVolume = DefaultOutlierRequestVolume
+	}
+	if c.Checker.Rise == 0 {
+		c.Checker.Rise = DefaultRise
+	}
+	if c.Checker.Fall == 0 {
+		c.Checker.Fall = DefaultFall
+	}
+	if c.Checker.History == 0 {
+		c.Checker.History = DefaultHistory
+	}
+	if c.SlowStart.Mode == "" {
+		c.SlowStart.Mode = DefaultSlowStartMode
+	}
+	if c.SlowStart.MinWeight == 0 {
+		c.SlowStart.MinWeight = DefaultSlowStartMinWeight
+	}
+	r := &c.Retry
+	if r.MaxAttempts == 0 {
+		r.MaxAttempts = DefaultMaxAttempts
+	}
+	if r.Methods == nil {
+		r.Methods = append([]string{}, DefaultRetryMethods...)
+	}
+	if r.Errors == nil {
+		r.Errors = append([]string{}, DefaultRetryErrors...)
+	}
+	if r.BufferLimit == 0 {
+		r.BufferLimit = DefaultBufferLimit
+	}
+	if r.BackoffBase == 0 {
+		r.BackoffBase = DefaultBackoffBase
+	}
+	if r.BackoffMax == 0 {
+		r.BackoffMax = DefaultBackoffMax
+	}
+}
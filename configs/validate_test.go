@@ -0,0 +1,166 @@
+package configs
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newValidConfig() *Config {
+	return &Config{
+		Port:        8000,
+		Nodes:       []Node{{URL: "http://localhost:8001"}},
+		HealthCheck: HealthCheck{Passive: PassiveHealthCheck{Period: Seconds(20)}},
+		Algorithm:   Algorithm{Name: "rr"},
+		Checker:     Checker{Name: "tcp"},
+	}
+}
+
+func TestValidateDefaults(t *testing.T) {
+	cfg := newValidConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Config.Validate() = %s, want no error", err)
+	}
+	if cfg.Admin.Host != DefaultAdminHost || cfg.Nodes[0].Weight != DefaultWeight ||
+		cfg.HealthCheck.Passive.InitialState != DefaultInitialState || cfg.Checker.Rise != DefaultRise ||
+		cfg.Checker.Fall != DefaultFall || cfg.Checker.History != DefaultHistory ||
+		cfg.SlowStart.Mode != DefaultSlowStartMode || cfg.SlowStart.MinWeight != DefaultSlowStartMinWeight {
+		t.Errorf("Config.Validate() = %+v, want defaults", cfg)
+	}
+	hc := cfg.HealthCheck
+	if hc.Passive.Timeout != DefaultPassiveTimeout || hc.Active.Window != DefaultBreakerWindow ||
+		hc.Active.OpenDuration != DefaultBreakerOpenDuration || hc.OutlierDetection.Interval != DefaultOutlierInterval ||
+		hc.OutlierDetection.MaxEjectionTime != DefaultOutlierMaxEjectionTime {
+		t.Errorf("Config.Validate() health check = %+v, want defaults", hc)
+	}
+	r := cfg.Retry
+	if r.MaxAttempts != DefaultMaxAttempts || !reflect.DeepEqual(r.Methods, DefaultRetryMethods) ||
+		!reflect.DeepEqual(r.Errors, DefaultRetryErrors) || r.BufferLimit != DefaultBufferLimit ||
+		r.BackoffBase != DefaultBackoffBase || r.BackoffMax != DefaultBackoffMax {
+		t.Errorf("Config.Validate() retry = %+v, want defaults", r)
+	}
+
+	// set keys are kept
+	cfg = newValidConfig()
+	cfg.Retry.Methods = []string{}
+	cfg.Checker.Rise = 3
+	if err := cfg.Validate(); err != nil || len(cfg.Retry.Methods) != 0 || cfg.Checker.Rise != 3 {
+		t.Errorf("Config.Validate() = %+v %v, want set keys kept", cfg, err)
+	}
+}
+
+func TestValidateInvalid(t *testing.T) {
+	tests := []struct {
+		path   string
+		modify func(c *Config)
+	}{
+		{"port", func(c *Config) { c.Port = 0 }},
+		{"admin.port", func(c *Config) { c.Admin.Port = 70000 }},
+		{"nodes", func(c *Config) { c.Nodes = nil }},
+		{"nodes[0].url", func(c *Config) { c.Nodes[0].URL = "localhost:8001" }},
+		{"nodes[0].url", func(c *Config) { c.Nodes[0].URL = "ftp://localhost:8001" }},
+		{"nodes[1].url", func(c *Config) { c.Nodes = append(c.Nodes, Node{URL: "https://localhost:8001"}) }},
+		{"nodes[0].weight", func(c *Config) { c.Nodes[0].Weight = -1 }},
+		{"nodes[0].proxyProtocol", func(c *Config) { c.Nodes[0].ProxyProtocol = 3 }},
+		{"nodes[0].healthCheck.url", func(c *Config) { c.Nodes[0].HealthCheck = &NodeHealthCheck{URL: "::"} }},
+		{"nodes[0].healthCheck.checker.name", func(c *Config) {
+			c.Nodes[0].HealthCheck = &NodeHealthCheck{Checker: &Checker{}}
+		}},
+		{"trustedProxies[1]", func(c *Config) { c.TrustedProxies = []string{"10.0.0.0/8", "10.0.0.0/33"} }},
+		{"healthCheck.passive.period", func(c *Config) { c.HealthCheck.Passive.Period = 0 }},
+		{"healthCheck.passive.jitter", func(c *Config) { c.HealthCheck.Passive.Jitter = 2 }},
+		{"healthCheck.passive.initialState", func(c *Config) { c.HealthCheck.Passive.InitialState = "alive" }},
+		{"healthCheck.active.errorRate", func(c *Config) { c.HealthCheck.Active.ErrorRate = -0.1 }},
+		{"healthCheck.outlierDetection.maxEjectionPercent", func(c *Config) {
+			c.HealthCheck.OutlierDetection.MaxEjectionPercent = 101
+		}},
+		{"slowStart.mode", func(c *Config) { c.SlowStart.Mode = "fast" }},
+		{"retry.statusCodes[0]", func(c *Config) { c.Retry.StatusCodes = []int{42} }},
+		{"retry.errors[0]", func(c *Config) { c.Retry.Errors = []string{"refused"} }},
+		{"retry.backoffMax", func(c *Config) { c.Retry.BackoffBase, c.Retry.BackoffMax = 100, 50 }},
+		{"retry.poolBudget.ratio", func(c *Config) { c.Retry.PoolBudget.Ratio = -1 }},
+		{"algorithm.name", func(c *Config) { c.Algorithm.Name = "" }},
+		{"checker.name", func(c *Config) { c.Checker.Name = "" }},
+	}
+	for _, test := range tests {
+		cfg := newValidConfig()
+		test.modify(cfg)
+		err := cfg.Validate()
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Path != test.path {
+			t.Errorf("Config.Validate() = %v, want an error of %s", err, test.path)
+		}
+	}
+}
+
+func TestNodeValidate(t *testing.T) {
+	n := Node{URL: "http://localhost:8001"}
+	if err := n.Validate(); err != nil || n.Weight != DefaultWeight {
+		t.Errorf("Node.Validate() = %+v %v, want default weight", n, err)
+	}
+
+	tests := map[string]Node{
+		"url":                      {URL: ""},
+		"weight":                   {URL: "http://localhost:8001", Weight: -1},
+		"proxyProtocol":            {URL: "http://localhost:8001", ProxyProtocol: 3},
+		"healthCheck.port":         {URL: "http://localhost:8001", HealthCheck: &NodeHealthCheck{Port: -1}},
+		"healthCheck.checker.name": {URL: "http://localhost:8001", HealthCheck: &NodeHealthCheck{Checker: &Checker{}}},
+	}
+	for path, n := range tests {
+		err := n.Validate()
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Path != path {
+			t.Errorf("Node.Validate() = %v, want an error of %s", err, path)
+		}
+	}
+}
+
+func TestNewStrict(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{"UnknownKey", "port: 8000\nhealthCheck:\n  passive:\n    perod: 5s\n",
+			[]string{"healthCheck.passive.perod: unknown key"}},
+		{"UnknownNodeKey", "nodes:\n  - url: http://localhost:8001\n    wieght: 2\n",
+			[]string{"nodes[0].wieght: unknown key"}},
+		{"Type", "port: eighty\nretry:\n  statusCodes: [502, x]\n",
+			[]string{"port: want integer, got string", "retry.statusCodes[1]: want integer, got string"}},
+		{"Duration", "healthCheck:\n  passive:\n    period: soon\n",
+			[]string{"healthCheck.passive.period: invalid duration: soon"}},
+		{"Validation", "port: 0\nnodes: []\n", []string{"port: must be between 1 and 65535", "nodes: must not be empty"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(writeFiles(t, map[string]string{"lb.yaml": test.data}), "lb.yaml")
+			_, err := New(path)
+			if err == nil {
+				t.Fatalf("New() = nil, want error")
+			}
+			for _, want := range test.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("New() = %q, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	data := `{"port": 8000, "nodes": ["http://localhost:8001"],
+		"healthCheck": {"active": {"maxRetry": 3, "retryDelay": 100}, "passive": {"period": 20, "timeout": 3}},
+		"algorithm": {"name": "ch"}, "checker": {"name": "tcp"}}`
+	cfg, err := New(filepath.Join(writeFiles(t, map[string]string{"config.json": data}), "config.json"))
+	if err != nil {
+		t.Fatalf("New(retryDelay) = %s, want no error", err)
+	}
+	if warnings := cfg.Warnings(); len(warnings) != 1 || !strings.Contains(warnings[0], "retryDelay") {
+		t.Errorf("Config.Warnings() = %v, want a warning of retryDelay", warnings)
+	}
+	if warnings := newValidConfig().Warnings(); len(warnings) != 0 {
+		t.Errorf("Config.Warnings() = %v, want none", warnings)
+	}
+}
@@ -0,0 +1,61 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Watch polls the config files of cfgPath every period until stop is closed. A value is sent on the returned channel
+// when a file is created, removed or modified. Changes made while the previous one isn't received yet are merged.
+func Watch(cfgPath string, period time.Duration, stop <-chan struct{}) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+	last := snapshot(cfgPath)
+	go func() {
+		t := time.NewTicker(period)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				current := snapshot(cfgPath)
+				if slices.Equal(current, last) {
+					continue
+				}
+				last = current
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return changes
+}
+
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// snapshot returns the state of the files read by New
+func snapshot(cfgPath string) []fileState {
+	paths := []string{cfgPath}
+	if info, err := os.Stat(cfgPath); err == nil && info.IsDir() {
+		paths = paths[:0]
+		names := append(slices.Clone(configFiles), algorithmFile, checkerFile)
+		for _, name := range names {
+			paths = append(paths, filepath.Join(cfgPath, name))
+		}
+	}
+	s := make([]fileState, len(paths))
+	for i, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			s[i] = fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+		}
+	}
+	return s
+}
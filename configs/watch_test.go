@@ -0,0 +1,62 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(`{"port": 8000}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	changes := Watch(dir, 10*time.Millisecond, stop)
+
+	select {
+	case <-changes:
+		t.Fatal("Watch() reported a change of untouched files")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(path, []byte(`{"port": 8080}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("Watch() didn't report a modified file")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "checker.json"), []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("Watch() didn't report a created file")
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lb.yaml")
+	if err := os.WriteFile(path, []byte("port: 8000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	changes := Watch(path, 10*time.Millisecond, stop)
+
+	if err := os.WriteFile(path, []byte("port: 8080\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("Watch() didn't report a modified config file")
+	}
+}
@@ -0,0 +1,404 @@
+package configs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseYAML parses the YAML subset used by config files into maps, slices, strings, float64 numbers, bools and nils,
+// like encoding/json does. It supports block mappings and sequences, flow mappings and sequences on a single line,
+// plain and quoted scalars and comments. Anchors, tags, block scalars and multiple documents aren't supported.
+func parseYAML(data []byte) (any, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(stripYAMLComment(raw), " \t\r")
+		text := strings.TrimLeft(raw, " ")
+		if text == "" || (i == 0 && text == "---") {
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("yaml: line %d: tabs are not allowed in indentation", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{number: i + 1, indent: len(raw) - len(text), text: text})
+	}
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+	v, err := p.parseNode(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return v, nil
+}
+
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	line := p.lines[len(p.lines)-1].number
+	if p.pos < len(p.lines) {
+		line = p.lines[p.pos].number
+	}
+	return fmt.Errorf("yaml: line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// parseNode parses the block node starting at the current line, indented by indent
+func (p *yamlParser) parseNode(indent int) (any, error) {
+	if isYAMLSequenceItem(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok := splitYAMLKey(p.lines[p.pos].text); !ok {
+		// a scalar alone, like a document of a single value
+		v, err := parseYAMLValue(p.lines[p.pos].text)
+		if err != nil {
+			return nil, p.errorf("%s", err.Error())
+		}
+		p.pos++
+		return v, nil
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *yamlParser) parseSequence(indent int) ([]any, error) {
+	items := make([]any, 0)
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSequenceItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		rest := strings.TrimLeft(line.text[1:], " ")
+		switch _, _, isMapping := splitYAMLKey(rest); {
+		case rest == "":
+			p.pos++
+			if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+				items = append(items, nil)
+				continue
+			}
+			item, err := p.parseNode(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		case isMapping:
+			// "- key: value" starts a mapping indented by the position of its first key
+			itemIndent := line.indent + len(line.text) - len(rest)
+			p.lines[p.pos] = yamlLine{number: line.number, indent: itemIndent, text: rest}
+			item, err := p.parseMapping(itemIndent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		default:
+			item, err := parseYAMLValue(rest)
+			if err != nil {
+				return nil, p.errorf("%s", err.Error())
+			}
+			items = append(items, item)
+			p.pos++
+		}
+	}
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return items, nil
+}
+
+func (p *yamlParser) parseMapping(indent int) (map[string]any, error) {
+	m := make(map[string]any)
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && !isYAMLSequenceItem(p.lines[p.pos].text) {
+		key, value, ok := splitYAMLKey(p.lines[p.pos].text)
+		if !ok {
+			return nil, p.errorf("want a key like \"key: value\"")
+		}
+		if _, dup := m[key]; dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		if value == "|" || value == ">" || strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+			return nil, p.errorf("block scalars are not supported")
+		}
+		p.pos++
+		if value != "" {
+			v, err := parseYAMLValue(value)
+			if err != nil {
+				p.pos--
+				return nil, p.errorf("%s", err.Error())
+			}
+			m[key] = v
+			continue
+		}
+		// a nested block is indented more, or is a sequence at the same indentation
+		if p.pos < len(p.lines) && (p.lines[p.pos].indent > indent ||
+			(p.lines[p.pos].indent == indent && isYAMLSequenceItem(p.lines[p.pos].text))) {
+			v, err := p.parseNode(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			continue
+		}
+		m[key] = nil
+	}
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return m, nil
+}
+
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey splits "key: value" at the first colon followed by a space or the end, outside quotes and flow nodes
+func splitYAMLKey(text string) (key, value string, ok bool) {
+	if text == "" || text[0] == '[' || text[0] == '{' {
+		return "", "", false
+	}
+	end := 0
+	if text[0] == '"' || text[0] == '\'' {
+		end = closingQuote(text)
+		if end < 0 {
+			return "", "", false
+		}
+	}
+	i := strings.Index(text[end:], ":")
+	for i >= 0 {
+		i += end
+		if i+1 == len(text) || text[i+1] == ' ' {
+			break
+		}
+		end = i + 1
+		i = strings.Index(text[end:], ":")
+	}
+	if i < 0 {
+		return "", "", false
+	}
+	key = strings.TrimSpace(text[:i])
+	if key != "" && (key[0] == '"' || key[0] == '\'') {
+		k, err := unquoteYAML(key)
+		if err != nil {
+			return "", "", false
+		}
+		key = k
+	}
+	return key, strings.TrimSpace(text[i+1:]), true
+}
+
+// closingQuote returns the index of the quote closing the quoted scalar at the start of s, or -1
+func closingQuote(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case q == '\'' && s[i] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+// stripYAMLComment removes a comment, which starts with # at the beginning or after a space, outside quotes
+func stripYAMLComment(line string) string {
+	var quote byte
+	prev := byte(' ')
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && strings.IndexByte(" :-[{,", prev) >= 0:
+			quote = c
+		case c == '#' && (prev == ' ' || prev == '\t'):
+			return line[:i]
+		}
+		prev = c
+	}
+	return line
+}
+
+func unquoteYAML(s string) (string, error) {
+	if len(s) < 2 || s[len(s)-1] != s[0] {
+		return "", fmt.Errorf("unterminated string %s", s)
+	}
+	if s[0] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	v, err := strconv.Unquote(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid string %s", s)
+	}
+	return v, nil
+}
+
+// parseYAMLValue parses a scalar or a flow node written on a single line
+func parseYAMLValue(s string) (any, error) {
+	f := &yamlFlow{s: s}
+	v, err := f.parse()
+	if err != nil {
+		return nil, err
+	}
+	if f.skipSpaces(); f.pos < len(f.s) {
+		return nil, fmt.Errorf("unexpected %q after value", f.s[f.pos:])
+	}
+	return v, nil
+}
+
+// yamlFlow parses flow nodes like [1, 2] and {a: 1}
+type yamlFlow struct {
+	s     string
+	pos   int
+	depth int // nesting of flow nodes
+}
+
+func (f *yamlFlow) skipSpaces() {
+	for f.pos < len(f.s) && f.s[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *yamlFlow) parse() (any, error) {
+	f.skipSpaces()
+	if f.pos == len(f.s) {
+		return nil, nil
+	}
+	switch c := f.s[f.pos]; c {
+	case '[':
+		return f.parseSequence()
+	case '{':
+		return f.parseMapping()
+	case '"', '\'':
+		end := closingQuote(f.s[f.pos:])
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated string %s", f.s[f.pos:])
+		}
+		v, err := unquoteYAML(f.s[f.pos : f.pos+end+1])
+		f.pos += end + 1
+		return v, err
+	case '&', '*', '!', '|', '>', '%', '@', '`':
+		return nil, fmt.Errorf("unsupported YAML %q", c)
+	}
+	return f.parsePlain(), nil
+}
+
+// parsePlain parses a plain scalar, which ends at a flow indicator inside flow nodes
+func (f *yamlFlow) parsePlain() any {
+	start := f.pos
+	for f.pos < len(f.s) {
+		c := f.s[f.pos]
+		if f.depth > 0 && (c == ',' || c == ']' || c == '}' || (c == ':' && (f.pos+1 == len(f.s) || f.s[f.pos+1] == ' '))) {
+			break
+		}
+		f.pos++
+	}
+	return plainYAMLScalar(strings.TrimSpace(f.s[start:f.pos]))
+}
+
+func (f *yamlFlow) parseSequence() ([]any, error) {
+	f.pos++ // [
+	f.depth++
+	items := make([]any, 0)
+	for {
+		f.skipSpaces()
+		if f.pos < len(f.s) && f.s[f.pos] == ']' {
+			f.pos++
+			f.depth--
+			return items, nil
+		}
+		item, err := f.parse()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if err := f.separator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *yamlFlow) parseMapping() (map[string]any, error) {
+	f.pos++ // {
+	f.depth++
+	m := make(map[string]any)
+	for {
+		f.skipSpaces()
+		if f.pos < len(f.s) && f.s[f.pos] == '}' {
+			f.pos++
+			f.depth--
+			return m, nil
+		}
+		k, err := f.parse()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			key = fmt.Sprint(k)
+		}
+		f.skipSpaces()
+		if f.pos == len(f.s) || f.s[f.pos] != ':' {
+			return nil, fmt.Errorf("want ':' after key %q", key)
+		}
+		f.pos++
+		if m[key], err = f.parse(); err != nil {
+			return nil, err
+		}
+		if err := f.separator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// separator consumes a comma, or leaves the closing character of a flow node
+func (f *yamlFlow) separator(closing byte) error {
+	f.skipSpaces()
+	switch {
+	case f.pos == len(f.s):
+		return fmt.Errorf("unterminated flow node, want %q", closing)
+	case f.s[f.pos] == ',':
+		f.pos++
+	case f.s[f.pos] != closing:
+		return fmt.Errorf("unexpected %q in flow node", f.s[f.pos])
+	}
+	return nil
+}
+
+// plainYAMLScalar resolves a plain scalar to null, a bool, a number or a string
+func plainYAMLScalar(s string) any {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if c := s[0]; (c >= '0' && c <= '9') || c == '-' || c == '+' || c == '.' {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return float64(n)
+		}
+		if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0o") {
+			if n, err := strconv.ParseInt(s, 0, 64); err == nil {
+				return float64(n)
+			}
+		}
+		if n, err := strconv.ParseFloat(s, 64); err == nil && !strings.ContainsAny(s, "xXpP_") {
+			return n
+		}
+	}
+	return s
+}
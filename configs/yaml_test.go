@@ -0,0 +1,93 @@
+package configs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want any
+	}{
+		{"Empty", "# only a comment\n", nil},
+		{"Scalars", `
+port: 8000
+ratio: 0.5
+enabled: true
+empty: ~
+name: "a # b"
+quoted: 'it''s'
+plain: http://localhost:8001 # comment
+`, map[string]any{
+			"port": 8000.0, "ratio": 0.5, "enabled": true, "empty": nil, "name": "a # b", "quoted": "it's",
+			"plain": "http://localhost:8001",
+		}},
+		{"Nested", `
+healthCheck:
+  passive:
+    period: 20s
+    jitter: 0.1
+`, map[string]any{"healthCheck": map[string]any{"passive": map[string]any{"period": "20s", "jitter": 0.1}}}},
+		{"Sequences", `
+nodes:
+  - http://localhost:8001
+  - url: http://localhost:8002
+    weight: 2
+methods:
+- GET
+- HEAD
+`, map[string]any{
+			"nodes": []any{
+				"http://localhost:8001",
+				map[string]any{"url": "http://localhost:8002", "weight": 2.0},
+			},
+			"methods": []any{"GET", "HEAD"},
+		}},
+		{"Flow", `
+statusCodes: [502, 503, 504]
+trustedCIDRs: []
+params: {replicas: 2, hashFunc: crc32, nested: {list: [a, "b, c"]}}
+`, map[string]any{
+			"statusCodes":  []any{502.0, 503.0, 504.0},
+			"trustedCIDRs": []any{},
+			"params": map[string]any{
+				"replicas": 2.0, "hashFunc": "crc32", "nested": map[string]any{"list": []any{"a", "b, c"}},
+			},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseYAML([]byte(test.data))
+			if err != nil {
+				t.Fatalf("parseYAML() error = %s, want no error", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseYAML() = %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseYAMLInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"DuplicateKey", "port: 1\nport: 2\n"},
+		{"Indentation", "a:\n    b: 1\n  c: 2\n"},
+		{"Tab", "a:\n\tb: 1\n"},
+		{"BlockScalar", "a: |\n  text\n"},
+		{"NotAKey", "a: 1\njust text\n"},
+		{"Unterminated", "a: \"text\n"},
+		{"Flow", "a: [1, 2\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if v, err := parseYAML([]byte(test.data)); err == nil {
+				t.Errorf("parseYAML(%q) = %#v, want error", test.data, v)
+			}
+		})
+	}
+}
@@ -3,10 +3,11 @@ package integration
 import (
 	"fmt"
 	"github.com/samanazadi/load-balancer/configs"
-	"github.com/samanazadi/load-balancer/internal/algorithm"
 	"github.com/samanazadi/load-balancer/internal/app"
-	"github.com/samanazadi/load-balancer/internal/checker"
+	"github.com/samanazadi/load-balancer/pkg/algorithm"
+	"github.com/samanazadi/load-balancer/pkg/checker"
 	"github.com/samanazadi/load-balancer/pkg/logging"
+	"github.com/samanazadi/load-balancer/pkg/retry"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
@@ -77,9 +78,9 @@ func TestBigBang(t *testing.T) {
 	}
 
 	// replace real nodes with mock nodes
-	cfg.Nodes = make([]string, 0, len(mocks))
+	cfg.Nodes = make([]configs.Node, 0, len(mocks))
 	for _, mock := range mocks {
-		cfg.Nodes = append(cfg.Nodes, mock.URL)
+		cfg.Nodes = append(cfg.Nodes, configs.Node{URL: mock.URL})
 	}
 
 	// checker
@@ -96,8 +97,17 @@ func TestBigBang(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// retry policy
+	pol, err := retry.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	// load balancer
-	lb := app.New(cfg, chk, alg, nil, nil)
+	lb, err := app.New(cfg, chk, alg, pol, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
 	t.Log("load balancer created")
 
 	server := &http.Server{
@@ -109,7 +119,7 @@ func TestBigBang(t *testing.T) {
 
 	go func() {
 		if err := server.ListenAndServe(); err != nil {
-			t.Fatalf("cannot start load balancer: %s", err.Error())
+			t.Errorf("cannot start load balancer: %s", err.Error())
 		}
 	}()
 
@@ -126,7 +136,7 @@ func TestBigBang(t *testing.T) {
 	t.Run("NextEligibleNode", func(t *testing.T) {
 		for i := 1; i < 1000*N; i++ {
 			r := httptest.NewRequest("GET", "localhost:"+strconv.Itoa(cfg.Port), nil)
-			n := lb.Algorithm.GetNextEligibleNode(r)
+			n := lb.Algorithm().GetNextEligibleNode(r)
 			if n == nil {
 				t.Fatal("GetNextEligibleNode() = nil but alive nodes are available")
 			}
@@ -135,16 +145,18 @@ func TestBigBang(t *testing.T) {
 
 	// passive health check: tcp
 	cfg.Checker.Name = checker.TCPType
-	cfg.HealthCheck.Passive.Timeout = 3
+	cfg.HealthCheck.Passive.Timeout = configs.Seconds(3)
 	chk, err = checker.New(cfg)
 	if err != nil {
 		t.Fatalf("cannot create checker: %s", err)
 	}
 
-	lb.ServerPool.ConnectionChecker = chk
-	mocks[1].Close()                        // shut down node 1
-	lb.StartPassiveHealthCheck(1, nil, nil) // run health check to mark node 1 as dead
-	time.Sleep(2 * time.Second)
+	lb.ServerPool.SetChecker(chk, cfg.Checker.Rise, cfg.Checker.Fall)
+	mocks[1].Close() // shut down node 1
+	// run health check to mark node 1 as dead, replacing the one started by app.New
+	tcpStop, tcpDone := make(chan bool), make(chan bool, 1)
+	lb.StartPassiveHealthCheck(time.Second, tcpStop, tcpDone)
+	time.Sleep(checksToFall(cfg))
 
 	found := false
 	for _, n := range lb.ServerPool.Nodes {
@@ -161,7 +173,7 @@ func TestBigBang(t *testing.T) {
 
 	// passive health check: http
 	cfg.Checker.Name = checker.HTTPType
-	cfg.HealthCheck.Passive.Timeout = 1
+	cfg.HealthCheck.Passive.Timeout = configs.Seconds(1)
 	cfg.Checker.Params = map[string]any{
 		"path":      "/ping",
 		"keyPhrase": "pong",
@@ -171,10 +183,12 @@ func TestBigBang(t *testing.T) {
 		t.Fatalf("cannot create checker: %s", err)
 	}
 
-	lb.ServerPool.ConnectionChecker = chk
-	mocks[2].Close()                        // shut down node 2
-	lb.StartPassiveHealthCheck(1, nil, nil) // run health check to mark node 2 as dead
-	time.Sleep(2 * time.Second)
+	lb.ServerPool.SetChecker(chk, cfg.Checker.Rise, cfg.Checker.Fall)
+	mocks[2].Close() // shut down node 2
+	// run health check to mark node 2 as dead, replacing the tcp one
+	httpStop, httpDone := make(chan bool), make(chan bool, 1)
+	lb.StartPassiveHealthCheck(time.Second, httpStop, httpDone)
+	time.Sleep(checksToFall(cfg))
 
 	found = false
 	for _, n := range lb.ServerPool.Nodes {
@@ -190,12 +204,26 @@ func TestBigBang(t *testing.T) {
 	}
 
 	// clean up
+	close(httpStop)
+	for _, done := range []chan bool{tcpDone, httpDone} {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Errorf("passive health check didn't stop")
+		}
+	}
 	for _, mock := range mocks {
 		mock.Close()
 	}
 	t.Log("integration test completed")
 }
 
+// checksToFall returns how long a health check running every second takes to mark a node as dead: fall consecutive
+// failed checks, the first of which is within the first second
+func checksToFall(cfg *configs.Config) time.Duration {
+	return time.Duration(cfg.Checker.Fall+1) * time.Second
+}
+
 func CreateTestServer(n int) *httptest.Server {
 	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/ping" {
@@ -0,0 +1,359 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/internal/app"
+	"github.com/samanazadi/load-balancer/pkg/checker"
+	"github.com/samanazadi/load-balancer/pkg/logging"
+	"github.com/samanazadi/load-balancer/pkg/models/node"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Handler serves the admin API of a load balancer:
+//
+//	GET    /ready                200 if the load balancer should get traffic, 503 while shutting down
+//	GET    /nodes                list nodes with their state
+//	POST   /nodes                add a node, the body is a node of config.json
+//	GET    /nodes/{host}         get a node, host is like "localhost:8001"
+//	DELETE /nodes/{host}         remove a node
+//	POST   /nodes/{host}/enable  enable a node
+//	POST   /nodes/{host}/disable take a node out of rotation regardless of its health
+//	PUT    /nodes/{host}/weight  set the weight of a node, the body is like {"weight": 2}
+//	POST   /nodes/{host}/drain   stop new requests to a node while in-flight ones finish
+//	GET    /nodes/{host}/drain   get a node, ?wait=30s waits up to 30s until no request is in flight
+//	DELETE /nodes/{host}/drain   put a draining node back into rotation
+type Handler struct {
+	LB    *app.LoadBalancer
+	Token string // required bearer token, none if empty
+}
+
+func New(lb *app.LoadBalancer, cfg *configs.Config) *Handler {
+	return &Handler{LB: lb, Token: cfg.Admin.Token}
+}
+
+// Addr returns the listening address of the admin API
+func Addr(cfg *configs.Config) string {
+	host := cfg.Admin.Host
+	if host == "" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(cfg.Admin.Port))
+}
+
+// NodeStatus is the state of a node
+type NodeStatus struct {
+	URL             string        `json:"url"`
+	Alive           bool          `json:"alive"`
+	Unknown         bool          `json:"unknown"`
+	Enabled         bool          `json:"enabled"`
+	Draining        bool          `json:"draining"`
+	Drained         bool          `json:"drained"` // draining and no request in flight
+	Ejected         bool          `json:"ejected"`
+	Circuit         string        `json:"circuit"`
+	Weight          int           `json:"weight"`
+	EffectiveWeight float64       `json:"effectiveWeight"`
+	Active          int64         `json:"active"`
+	Transitions     int           `json:"transitions"`
+	LastTransition  *time.Time    `json:"lastTransition,omitempty"`
+	Checks          []CheckStatus `json:"checks"`
+}
+
+// CheckStatus is a passive health check result
+type CheckStatus struct {
+	Healthy    bool          `json:"healthy"`
+	Time       time.Time     `json:"time"`
+	Latency    string        `json:"latency"`
+	Reason     string        `json:"reason,omitempty"`
+	StatusCode int           `json:"statusCode,omitempty"`
+	Snippet    string        `json:"snippet,omitempty"`
+	Name       string        `json:"name,omitempty"`
+	Checks     []CheckStatus `json:"checks,omitempty"`
+}
+
+func newNodeStatus(n *node.Node) NodeStatus {
+	h := n.Health()
+	s := NodeStatus{
+		URL:             n.URL.String(),
+		Alive:           h.Alive,
+		Unknown:         h.Unknown,
+		Enabled:         n.IsEnabled(),
+		Draining:        n.IsDraining(),
+		Drained:         n.IsDrained(),
+		Ejected:         n.IsEjected(),
+		Circuit:         n.Breaker.State().String(),
+		Weight:          n.Weight(),
+		EffectiveWeight: n.EffectiveWeight(),
+		Active:          n.Active(),
+		Transitions:     h.Transitions,
+		Checks:          newCheckStatuses(n.Checks()),
+	}
+	if !h.LastTransition.IsZero() {
+		s.LastTransition = &h.LastTransition
+	}
+	return s
+}
+
+func newCheckStatuses(results []checker.Result) []CheckStatus {
+	statuses := make([]CheckStatus, 0, len(results))
+	for _, r := range results {
+		statuses = append(statuses, CheckStatus{
+			Healthy:    r.Healthy,
+			Time:       r.Time,
+			Latency:    r.Latency.String(),
+			Reason:     r.Reason,
+			StatusCode: r.StatusCode,
+			Snippet:    r.Snippet,
+			Name:       r.Name,
+			Checks:     newCheckStatuses(r.Checks),
+		})
+	}
+	return statuses
+}
+
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if strings.Trim(r.URL.Path, "/") == "ready" { // for probes, without token
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			methodNotAllowed(rw, http.MethodGet, http.MethodHead)
+			return
+		}
+		h.ready(rw)
+		return
+	}
+	if !h.authorized(r) {
+		rw.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(rw, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	if path == "nodes" {
+		switch r.Method {
+		case http.MethodGet:
+			h.listNodes(rw)
+		case http.MethodPost:
+			h.addNode(rw, r)
+		default:
+			methodNotAllowed(rw, http.MethodGet, http.MethodPost)
+		}
+		return
+	}
+
+	host, action, _ := strings.Cut(strings.TrimPrefix(path, "nodes/"), "/")
+	if !strings.HasPrefix(path, "nodes/") || host == "" {
+		writeError(rw, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	switch action {
+	case "":
+		switch r.Method {
+		case http.MethodGet:
+			h.getNode(rw, host)
+		case http.MethodDelete:
+			h.removeNode(rw, host)
+		default:
+			methodNotAllowed(rw, http.MethodGet, http.MethodDelete)
+		}
+	case "enable", "disable":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(rw, http.MethodPost)
+			return
+		}
+		h.setEnabled(rw, host, action == "enable")
+	case "weight":
+		if r.Method != http.MethodPut {
+			methodNotAllowed(rw, http.MethodPut)
+			return
+		}
+		h.setWeight(rw, r, host)
+	case "drain":
+		switch r.Method {
+		case http.MethodPost:
+			h.drain(rw, host)
+		case http.MethodGet:
+			h.waitDrained(rw, r, host)
+		case http.MethodDelete:
+			h.undrain(rw, host)
+		default:
+			methodNotAllowed(rw, http.MethodPost, http.MethodGet, http.MethodDelete)
+		}
+	default:
+		writeError(rw, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	if h.Token == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) == 1
+}
+
+func (h *Handler) ready(rw http.ResponseWriter) {
+	ready := h.LB.Ready()
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(rw, status, map[string]bool{"ready": ready})
+}
+
+func (h *Handler) listNodes(rw http.ResponseWriter) {
+	nodes := h.LB.ServerPool.List()
+	statuses := make([]NodeStatus, 0, len(nodes))
+	for _, n := range nodes {
+		statuses = append(statuses, newNodeStatus(n))
+	}
+	writeJSON(rw, http.StatusOK, statuses)
+}
+
+func (h *Handler) addNode(rw http.ResponseWriter, r *http.Request) {
+	var nodeCfg configs.Node
+	if err := json.NewDecoder(r.Body).Decode(&nodeCfg); err != nil {
+		writeError(rw, http.StatusBadRequest, fmt.Errorf("invalid node: %s", err.Error()))
+		return
+	}
+	// validated like the nodes of the config, its transport and health checker are built by AddNode
+	if err := nodeCfg.Validate(); err != nil {
+		writeError(rw, http.StatusBadRequest, fmt.Errorf("invalid node: %s", err.Error()))
+		return
+	}
+	n, err := h.LB.AddNode(nodeCfg)
+	if err != nil {
+		writeError(rw, errorStatus(err), err)
+		return
+	}
+	writeJSON(rw, http.StatusCreated, newNodeStatus(n))
+}
+
+func (h *Handler) getNode(rw http.ResponseWriter, host string) {
+	n := h.LB.ServerPool.Node(host)
+	if n == nil {
+		writeError(rw, http.StatusNotFound, fmt.Errorf("%w: %s", app.ErrNodeNotFound, host))
+		return
+	}
+	writeJSON(rw, http.StatusOK, newNodeStatus(n))
+}
+
+func (h *Handler) removeNode(rw http.ResponseWriter, host string) {
+	n, err := h.LB.RemoveNode(host)
+	if err != nil {
+		writeError(rw, errorStatus(err), err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, newNodeStatus(n))
+}
+
+func (h *Handler) setEnabled(rw http.ResponseWriter, host string, enabled bool) {
+	n := h.LB.ServerPool.Node(host)
+	if n == nil {
+		writeError(rw, http.StatusNotFound, fmt.Errorf("%w: %s", app.ErrNodeNotFound, host))
+		return
+	}
+	n.SetEnabled(enabled)
+	logging.Logger.Printf("admin, %s: enabled = %t", n.URL, enabled)
+	writeJSON(rw, http.StatusOK, newNodeStatus(n))
+}
+
+func (h *Handler) setWeight(rw http.ResponseWriter, r *http.Request, host string) {
+	var body struct {
+		Weight *int `json:"weight"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Weight == nil || *body.Weight < 1 {
+		writeError(rw, http.StatusBadRequest, errors.New(`invalid weight, want a body like {"weight": 2}`))
+		return
+	}
+	n := h.LB.ServerPool.Node(host)
+	if n == nil {
+		writeError(rw, http.StatusNotFound, fmt.Errorf("%w: %s", app.ErrNodeNotFound, host))
+		return
+	}
+	n.SetWeight(*body.Weight)
+	logging.Logger.Printf("admin, %s: weight = %d", n.URL, *body.Weight)
+	writeJSON(rw, http.StatusOK, newNodeStatus(n))
+}
+
+func (h *Handler) drain(rw http.ResponseWriter, host string) {
+	n := h.LB.ServerPool.Node(host)
+	if n == nil {
+		writeError(rw, http.StatusNotFound, fmt.Errorf("%w: %s", app.ErrNodeNotFound, host))
+		return
+	}
+	n.Drain()
+	logging.Logger.Printf("admin, %s: draining, %d requests in flight", n.URL, n.Active())
+	writeJSON(rw, http.StatusOK, newNodeStatus(n))
+}
+
+func (h *Handler) waitDrained(rw http.ResponseWriter, r *http.Request, host string) {
+	var wait time.Duration
+	if v := r.URL.Query().Get("wait"); v != "" {
+		var err error
+		if wait, err = time.ParseDuration(v); err != nil || wait < 0 {
+			writeError(rw, http.StatusBadRequest, fmt.Errorf("invalid wait: %s", v))
+			return
+		}
+	}
+	n := h.LB.ServerPool.Node(host)
+	if n == nil {
+		writeError(rw, http.StatusNotFound, fmt.Errorf("%w: %s", app.ErrNodeNotFound, host))
+		return
+	}
+	if drained := n.Drained(); wait > 0 && drained != nil {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-drained:
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	writeJSON(rw, http.StatusOK, newNodeStatus(n))
+}
+
+func (h *Handler) undrain(rw http.ResponseWriter, host string) {
+	n := h.LB.ServerPool.Node(host)
+	if n == nil {
+		writeError(rw, http.StatusNotFound, fmt.Errorf("%w: %s", app.ErrNodeNotFound, host))
+		return
+	}
+	n.Undrain()
+	logging.Logger.Printf("admin, %s: back in rotation", n.URL)
+	writeJSON(rw, http.StatusOK, newNodeStatus(n))
+}
+
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, app.ErrNodeExists):
+		return http.StatusConflict
+	case errors.Is(err, app.ErrNodeNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func methodNotAllowed(rw http.ResponseWriter, allowed ...string) {
+	rw.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(rw, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+}
+
+func writeError(rw http.ResponseWriter, status int, err error) {
+	writeJSON(rw, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(rw http.ResponseWriter, status int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		logging.Logger.Printf("admin, cannot write response: %s", err.Error())
+	}
+}
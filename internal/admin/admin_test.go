@@ -0,0 +1,196 @@
+package admin
+
+import (
+	"encoding/json"
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/internal/app"
+	"github.com/samanazadi/load-balancer/pkg/algorithm"
+	"github.com/samanazadi/load-balancer/pkg/logging"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestHandler(t *testing.T, token string) *Handler {
+	logging.Logger = log.New(io.Discard, "", 0)
+	cfg := &configs.Config{Nodes: []configs.Node{{URL: "http://localhost:8001"}, {URL: "http://localhost:8002"}}}
+	cfg.HealthCheck.Passive.Period = configs.Seconds(3600)
+	cfg.Admin.Token = token
+	lb, err := app.New(cfg, nil, algorithm.NewRoundRobin(), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(lb, cfg)
+}
+
+func do(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rw
+}
+
+func TestNodes(t *testing.T) {
+	h := newTestHandler(t, "")
+	nextHost := func() string {
+		n := h.LB.Algorithm().GetNextEligibleNode(httptest.NewRequest(http.MethodGet, "/", nil))
+		if n == nil {
+			return ""
+		}
+		return n.URL.Host
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantNodes  []string // nodes served by the algorithm afterwards
+	}{
+		{"List", http.MethodGet, "/nodes", "", http.StatusOK, []string{"localhost:8001", "localhost:8002"}},
+		{"Get", http.MethodGet, "/nodes/localhost:8002", "", http.StatusOK, nil},
+		{"GetNotFound", http.MethodGet, "/nodes/localhost:9000", "", http.StatusNotFound, nil},
+		{"Add", http.MethodPost, "/nodes", `{"url": "http://localhost:8003", "weight": 2}`, http.StatusCreated,
+			[]string{"localhost:8001", "localhost:8002", "localhost:8003"}},
+		{"AddString", http.MethodPost, "/nodes", `"http://localhost:8004"`, http.StatusCreated,
+			[]string{"localhost:8001", "localhost:8002", "localhost:8003", "localhost:8004"}},
+		{"AddExisting", http.MethodPost, "/nodes", `"http://localhost:8001"`, http.StatusConflict, nil},
+		{"AddInvalid", http.MethodPost, "/nodes", `{"weight": 2}`, http.StatusBadRequest, nil},
+		{"AddInvalidURL", http.MethodPost, "/nodes", `"http://local host"`, http.StatusBadRequest, nil},
+		{"AddInvalidScheme", http.MethodPost, "/nodes", `"ftp://localhost:8005"`, http.StatusBadRequest, nil},
+		{"AddNoHost", http.MethodPost, "/nodes", `"http://"`, http.StatusBadRequest, nil},
+		{"AddInvalidWeight", http.MethodPost, "/nodes", `{"url": "http://localhost:8005", "weight": -1}`,
+			http.StatusBadRequest, nil},
+		{"AddInvalidProxyProtocol", http.MethodPost, "/nodes", `{"url": "http://localhost:8005", "proxyProtocol": 3}`,
+			http.StatusBadRequest, nil},
+		{"AddInvalidChecker", http.MethodPost, "/nodes",
+			`{"url": "http://localhost:8005", "healthCheck": {"checker": {"name": "nope"}}}`, http.StatusBadRequest, nil},
+		{"Remove", http.MethodDelete, "/nodes/localhost:8004", "", http.StatusOK,
+			[]string{"localhost:8001", "localhost:8002", "localhost:8003"}},
+		{"RemoveNotFound", http.MethodDelete, "/nodes/localhost:8004", "", http.StatusNotFound, nil},
+		{"Disable", http.MethodPost, "/nodes/localhost:8002/disable", "", http.StatusOK,
+			[]string{"localhost:8001", "localhost:8003"}},
+		{"DisableGet", http.MethodGet, "/nodes/localhost:8002/disable", "", http.StatusMethodNotAllowed, nil},
+		{"Enable", http.MethodPost, "/nodes/localhost:8002/enable", "", http.StatusOK,
+			[]string{"localhost:8001", "localhost:8002", "localhost:8003"}},
+		{"Drain", http.MethodPost, "/nodes/localhost:8002/drain", "", http.StatusOK,
+			[]string{"localhost:8001", "localhost:8003"}},
+		{"WaitDrained", http.MethodGet, "/nodes/localhost:8002/drain?wait=1s", "", http.StatusOK, nil},
+		{"WaitDrainedInvalid", http.MethodGet, "/nodes/localhost:8002/drain?wait=soon", "", http.StatusBadRequest, nil},
+		{"Undrain", http.MethodDelete, "/nodes/localhost:8002/drain", "", http.StatusOK,
+			[]string{"localhost:8001", "localhost:8002", "localhost:8003"}},
+		{"Weight", http.MethodPut, "/nodes/localhost:8002/weight", `{"weight": 3}`, http.StatusOK, nil},
+		{"WeightInvalid", http.MethodPut, "/nodes/localhost:8002/weight", `{"weight": 0}`, http.StatusBadRequest, nil},
+		{"UnknownAction", http.MethodPost, "/nodes/localhost:8002/restart", "", http.StatusNotFound, nil},
+		{"UnknownPath", http.MethodGet, "/", "", http.StatusNotFound, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rw := do(h, test.method, test.path, test.body)
+			if rw.Code != test.wantStatus {
+				t.Fatalf("%s %s = %d %s, want %d", test.method, test.path, rw.Code, rw.Body, test.wantStatus)
+			}
+			if test.wantNodes == nil {
+				return
+			}
+			served := map[string]bool{}
+			for i := 0; i < 2*len(test.wantNodes); i++ {
+				served[nextHost()] = true
+			}
+			for _, host := range test.wantNodes {
+				if !served[host] {
+					t.Errorf("%s %s: node %s isn't served, served %v", test.method, test.path, host, served)
+				}
+			}
+			if len(served) != len(test.wantNodes) {
+				t.Errorf("%s %s: served %v, want %v", test.method, test.path, served, test.wantNodes)
+			}
+		})
+	}
+
+	var statuses []NodeStatus
+	if err := json.Unmarshal(do(h, http.MethodGet, "/nodes", "").Body.Bytes(), &statuses); err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 3 || statuses[1].Weight != 3 || !statuses[1].Enabled || statuses[2].Weight != 2 {
+		t.Errorf("GET /nodes = %+v, want 3 nodes with weights 1, 3, 2", statuses)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	h := newTestHandler(t, "")
+	n := h.LB.ServerPool.Node("localhost:8001")
+	var status NodeStatus
+
+	json.Unmarshal(do(h, http.MethodPost, "/nodes/localhost:8001/drain", "").Body.Bytes(), &status)
+	if !status.Draining || !status.Drained {
+		t.Errorf("POST /nodes/localhost:8001/drain of an idle node = %+v, want draining and drained", status)
+	}
+	if !n.IsDraining() {
+		t.Errorf("node isn't draining after POST /nodes/localhost:8001/drain")
+	}
+
+	json.Unmarshal(do(h, http.MethodDelete, "/nodes/localhost:8001/drain", "").Body.Bytes(), &status)
+	if status.Draining || status.Drained || n.IsDraining() {
+		t.Errorf("DELETE /nodes/localhost:8001/drain = %+v, want not draining", status)
+	}
+
+	start := time.Now()
+	json.Unmarshal(do(h, http.MethodGet, "/nodes/localhost:8001/drain?wait=1s", "").Body.Bytes(), &status)
+	if time.Since(start) > 500*time.Millisecond || status.Draining {
+		t.Errorf("GET /nodes/localhost:8001/drain?wait=1s of a node in rotation waited %s, want no wait",
+			time.Since(start))
+	}
+
+	n.Acquire() // a request in flight keeps the node from draining
+	n.Drain()
+	time.AfterFunc(50*time.Millisecond, n.Undrain)
+	start = time.Now()
+	json.Unmarshal(do(h, http.MethodGet, "/nodes/localhost:8001/drain?wait=5s", "").Body.Bytes(), &status)
+	if time.Since(start) > 2*time.Second || status.Draining {
+		t.Errorf("GET /nodes/localhost:8001/drain?wait=5s undrained meanwhile waited %s, got %+v, want no timeout",
+			time.Since(start), status)
+	}
+}
+
+func TestReady(t *testing.T) {
+	h := newTestHandler(t, "secret") // no token needed
+	if rw := do(h, http.MethodGet, "/ready", ""); rw.Code != http.StatusOK {
+		t.Errorf("GET /ready = %d, want %d", rw.Code, http.StatusOK)
+	}
+	h.LB.EnterLameduck()
+	if rw := do(h, http.MethodGet, "/ready", ""); rw.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /ready in lameduck = %d, want %d", rw.Code, http.StatusServiceUnavailable)
+	}
+	if rw := do(h, http.MethodPost, "/ready", ""); rw.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /ready = %d, want %d", rw.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestToken(t *testing.T) {
+	h := newTestHandler(t, "secret")
+	tests := []struct {
+		header     string
+		wantStatus int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"secret", http.StatusUnauthorized},
+		{"Bearer secret", http.StatusOK},
+	}
+	for _, test := range tests {
+		rw := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/nodes", nil)
+		if test.header != "" {
+			r.Header.Set("Authorization", test.header)
+		}
+		h.ServeHTTP(rw, r)
+		if rw.Code != test.wantStatus {
+			t.Errorf("GET /nodes with Authorization %q = %d, want %d", test.header, rw.Code, test.wantStatus)
+		}
+	}
+}
@@ -1,60 +1,326 @@
 package app
 
 import (
+	"errors"
+	"fmt"
 	"github.com/samanazadi/load-balancer/configs"
-	"github.com/samanazadi/load-balancer/internal/algorithm"
-	"github.com/samanazadi/load-balancer/internal/checker"
-	"github.com/samanazadi/load-balancer/internal/models/node"
+	"github.com/samanazadi/load-balancer/internal/proxyproto"
+	"github.com/samanazadi/load-balancer/pkg/algorithm"
+	"github.com/samanazadi/load-balancer/pkg/checker"
+	"github.com/samanazadi/load-balancer/pkg/clientip"
 	"github.com/samanazadi/load-balancer/pkg/logging"
+	"github.com/samanazadi/load-balancer/pkg/models/node"
+	"github.com/samanazadi/load-balancer/pkg/retry"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// initial states of nodes
+const (
+	UpState      = "up"
+	DownState    = "down"
+	UnknownState = "unknown"
 )
 
 // LoadBalancer is a server pool along an algorithm
 type LoadBalancer struct {
-	ServerPool ServerPool
-	Algorithm  algorithm.Algorithm
+	ServerPool  ServerPool
+	Resolver    *clientip.Resolver // real client IP for logs
+	RetryPolicy *retry.Policy      // nil for a single attempt
+	algorithm   atomic.Pointer[algorithm.Algorithm]
+	cfg         *configs.Config             // applied config, for creating nodes at runtime
+	nodeCfgs    map[*node.Node]configs.Node // config of every node, for diffing on reload
+	mux         sync.Mutex                  // serializes node changes, so the algorithm gets them in order
+	lameduck    atomic.Bool                 // shutting down, reported as not ready
+}
+
+// EnterLameduck reports the load balancer as not ready while it keeps serving, before shutdown
+func (lb *LoadBalancer) EnterLameduck() {
+	lb.lameduck.Store(true)
+}
+
+// Ready reports whether the load balancer should get traffic
+func (lb *LoadBalancer) Ready() bool {
+	return !lb.lameduck.Load()
+}
+
+// Active returns the number of in-flight requests of all nodes, including upgraded connections
+func (lb *LoadBalancer) Active() int64 {
+	var active int64
+	for _, n := range lb.ServerPool.List() {
+		active += n.Active()
+	}
+	return active
+}
+
+// Algorithm returns the balancing algorithm
+func (lb *LoadBalancer) Algorithm() algorithm.Algorithm {
+	return *lb.algorithm.Load()
+}
+
+func (lb *LoadBalancer) setAlgorithm(alg algorithm.Algorithm) {
+	lb.algorithm.Store(&alg)
 }
 
 func (lb *LoadBalancer) SetNodeAlive(url *url.URL, alive bool) {
 	lb.ServerPool.SetNodeAlive(url, alive)
 }
 
-// ServeHTTP route request based on algorithm
+// ReportOutcome feeds outlier detection with the result of a request
+func (lb *LoadBalancer) ReportOutcome(n *node.Node, o node.Outcome) {
+	lb.ServerPool.Outliers.Report(lb.ServerPool.List(), n, o)
+}
+
+// ServeHTTP route request based on algorithm, retrying failed attempts on other nodes
 func (lb *LoadBalancer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
-	if n := lb.Algorithm.GetNextEligibleNode(r); n != nil {
-		n.ReverseProxy.ServeHTTP(rw, r)
+	if lb.RetryPolicy == nil {
+		if n := lb.nextNode(r); n != nil {
+			n.Serve(rw, r)
+			return
+		}
+		lb.serviceUnavailable(rw, r)
+		return
+	}
+
+	state, r, err := retry.NewState(lb.RetryPolicy, r, lb.ServerPool.RetryBudget)
+	if err != nil {
+		http.Error(rw, "Cannot read request body", http.StatusBadRequest)
 		return
 	}
-	logging.Logger.Println("no node is available")
+	for {
+		n := lb.nextNode(r)
+		if n == nil {
+			break
+		}
+		state.Start(r, n.URL)
+		if !lb.ServerPool.hasUntriedNode(r) {
+			state.SetLast()
+		}
+		n.Serve(rw, r)
+		if !state.Pending() {
+			return
+		}
+		logging.Logger.Printf("retrying request, attempt %d failed on %s: %s", state.Attempts(), n.URL, state.Err())
+		if !state.Wait(r.Context()) {
+			break
+		}
+	}
+
+	if state.Attempts() == 0 {
+		lb.serviceUnavailable(rw, r)
+		return
+	}
+	logging.Logger.Printf("request failed after %d attempts: %s", state.Attempts(), state.Err())
+	rw.WriteHeader(http.StatusBadGateway)
+}
+
+// nextNode returns the next eligible node with the request counted as in-flight on it. A node which starts draining
+// after the algorithm picked it backs off, and the algorithm picks again.
+func (lb *LoadBalancer) nextNode(r *http.Request) *node.Node {
+	for range lb.ServerPool.List() {
+		n := lb.Algorithm().GetNextEligibleNode(r)
+		if n == nil || n.Acquire() {
+			return n
+		}
+	}
+	return nil
+}
+
+func (lb *LoadBalancer) serviceUnavailable(rw http.ResponseWriter, r *http.Request) {
+	logging.Logger.Printf("no node is available for client %s", lb.Resolver.ClientIP(r))
 	http.Error(rw, "Service not available", http.StatusServiceUnavailable)
 }
 
 // StartPassiveHealthCheck starts passive health check daemon
-func (lb *LoadBalancer) StartPassiveHealthCheck(period int, stop <-chan bool, done chan<- bool) {
+func (lb *LoadBalancer) StartPassiveHealthCheck(period time.Duration, stop <-chan bool, done chan<- bool) {
 	lb.ServerPool.StartPassiveHealthCheck(period, stop, done)
 }
 
-func New(cfg *configs.Config, chk checker.ConnectionChecker, alg algorithm.Algorithm,
-	stop <-chan bool, done chan<- bool) *LoadBalancer {
-	lb := &LoadBalancer{}
+// AddNode creates a node at runtime and adds it to the pool and the algorithm
+func (lb *LoadBalancer) AddNode(nodeCfg configs.Node) (*node.Node, error) {
+	lb.mux.Lock()
+	defer lb.mux.Unlock()
+	n, err := newNode(lb.cfg, nodeCfg, lb)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := lb.ServerPool.AddNode(n)
+	if err != nil {
+		return nil, err
+	}
+	lb.nodeCfgs[n] = nodeCfg
+	lb.Algorithm().SetNodes(nodes)
+	logging.Logger.Printf("node added: %s", n.URL)
+	return n, nil
+}
+
+// RemoveNode removes the node whose host (like "localhost:8001") is host from the pool and the algorithm.
+// In-flight requests of the node finish.
+func (lb *LoadBalancer) RemoveNode(host string) (*node.Node, error) {
+	lb.mux.Lock()
+	defer lb.mux.Unlock()
+	n, nodes, err := lb.ServerPool.RemoveNode(host)
+	if err != nil {
+		return nil, err
+	}
+	delete(lb.nodeCfgs, n)
+	lb.Algorithm().SetNodes(nodes)
+	logging.Logger.Printf("node removed: %s", n.URL)
+	return n, nil
+}
+
+// newNode creates a node with its own settings: transport, weight and health check overrides. An invalid setting is
+// a configs.FieldError whose path is relative to the node.
+func newNode(cfg *configs.Config, nodeCfg configs.Node, lb *LoadBalancer) (*node.Node, error) {
+	nodeURL, err := url.Parse(nodeCfg.URL)
+	if err != nil {
+		return nil, &configs.FieldError{Path: "url", Message: fmt.Sprintf("cannot parse node URL: %s", nodeCfg.URL)}
+	}
+	rt, chk, err := buildNode(cfg, nodeCfg)
+	if err != nil {
+		return nil, err
+	}
+	passive := cfg.HealthCheck.Passive
+	alive := passive.InitialState != DownState && passive.InitialState != UnknownState
+	n := node.New(nodeURL, alive, cfg, lb)
+	if passive.InitialState == UnknownState || passive.StartupTimeout > 0 {
+		n.SetUnknown(alive)
+	}
+	n.SetWeight(nodeCfg.Weight)
+	if rt != nil {
+		n.SetTransport(rt)
+	}
+
+	hc := nodeCfg.HealthCheck
+	if hc == nil {
+		return n, nil
+	}
+	target := *nodeURL
+	if hc.URL != "" {
+		u, err := url.Parse(hc.URL)
+		if err != nil {
+			return nil, &configs.FieldError{Path: "healthCheck.url",
+				Message: fmt.Sprintf("cannot parse health check URL: %s", hc.URL)}
+		}
+		target = *u
+	}
+	if hc.Port != 0 {
+		target.Host = net.JoinHostPort(target.Hostname(), strconv.Itoa(hc.Port))
+	}
+	n.HealthURL = &target
+	n.Checker = chk
+	return n, nil
+}
+
+// buildNode creates the PROXY protocol transport and the health checker override of a node, each nil if the node
+// doesn't set it
+func buildNode(cfg *configs.Config, nodeCfg configs.Node) (http.RoundTripper, checker.ConnectionChecker, error) {
+	var rt http.RoundTripper
+	if nodeCfg.ProxyProtocol != 0 {
+		var err error
+		if rt, err = proxyproto.NewTransport(nodeCfg.ProxyProtocol); err != nil {
+			return nil, nil, &configs.FieldError{Path: "proxyProtocol", Message: err.Error()}
+		}
+	}
+
+	hc := nodeCfg.HealthCheck
+	if hc == nil || (hc.Checker == nil && hc.Path == "") {
+		return rt, nil, nil
+	}
+	c := cfg.Checker
+	if hc.Checker != nil {
+		c = *hc.Checker
+	}
+	if hc.Path != "" {
+		params := map[string]any{"path": hc.Path}
+		for k, v := range c.Params {
+			if k != "path" {
+				params[k] = v
+			}
+		}
+		c.Params = params
+	}
+	chk, err := checker.Build(c, cfg)
+	if err != nil {
+		return nil, nil, &configs.FieldError{Path: "healthCheck", Message: err.Error()}
+	}
+	return rt, chk, nil
+}
+
+// CheckNodes builds the transport and the health checker override of every node of cfg, like New does, to find
+// invalid nodes without creating them. Its error joins a configs.FieldError for every invalid node.
+func CheckNodes(cfg *configs.Config) error {
+	var errs []error
+	for i, nodeCfg := range cfg.Nodes {
+		if _, _, err := buildNode(cfg, nodeCfg); err != nil {
+			errs = append(errs, nodeError(i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// nodeError prefixes the path of an error of newNode by the index of the node, like nodes[1].healthCheck
+func nodeError(i int, err error) error {
+	var fieldErr *configs.FieldError
+	if errors.As(err, &fieldErr) {
+		return &configs.FieldError{Path: fmt.Sprintf("nodes[%d].%s", i, fieldErr.Path), Message: fieldErr.Message}
+	}
+	return &configs.FieldError{Path: fmt.Sprintf("nodes[%d]", i), Message: err.Error()}
+}
+
+// New creates a load balancer of the nodes of cfg and starts its passive health check. It returns an error of the
+// first node which cannot be created.
+func New(cfg *configs.Config, chk checker.ConnectionChecker, alg algorithm.Algorithm, pol *retry.Policy,
+	stop <-chan bool, done chan<- bool) (*LoadBalancer, error) {
+	lb := &LoadBalancer{RetryPolicy: pol, cfg: cfg, nodeCfgs: make(map[*node.Node]configs.Node, len(cfg.Nodes))}
+	resolver, err := clientip.New(cfg)
+	if err != nil {
+		logging.Logger.Printf("invalid trustedProxies, no proxy is trusted: %s", err.Error())
+	}
+	lb.Resolver = resolver
+	switch cfg.HealthCheck.Passive.InitialState {
+	case "", UpState, DownState, UnknownState:
+	default:
+		logging.Logger.Printf("invalid initialState, nodes start up: %s", cfg.HealthCheck.Passive.InitialState)
+	}
 	nodes := make([]*node.Node, 0, len(cfg.Nodes))
 
-	for _, nodeURLString := range cfg.Nodes {
-		nodeURL, err := url.Parse(nodeURLString)
+	for i, nodeCfg := range cfg.Nodes {
+		n, err := newNode(cfg, nodeCfg, lb)
 		if err != nil {
-			logging.Logger.Printf("cannot parse node URL: %s", nodeURLString)
-			continue
+			return nil, nodeError(i, err)
 		}
-		nodes = append(nodes, node.New(nodeURL, true, cfg, lb))
-		logging.Logger.Printf("node added: %s", nodeURLString)
+		nodes = append(nodes, n)
+		lb.nodeCfgs[n] = nodeCfg
+		logging.Logger.Printf("node added: %s", nodeCfg.URL)
 	}
 
 	lb.ServerPool = NewServerPool(nodes, chk)
+	if cfg.Checker.Rise > 0 {
+		lb.ServerPool.Rise = cfg.Checker.Rise
+	}
+	if cfg.Checker.Fall > 0 {
+		lb.ServerPool.Fall = cfg.Checker.Fall
+	}
+	lb.ServerPool.UnhealthyPeriod = cfg.HealthCheck.Passive.UnhealthyPeriod.Std()
+	lb.ServerPool.Jitter = cfg.HealthCheck.Passive.Jitter
+	lb.ServerPool.SetMaxConcurrentChecks(cfg.HealthCheck.Passive.MaxConcurrent)
+	lb.ServerPool.Outliers = NewOutlierDetector(cfg)
+	if lb.ServerPool.RetryBudget, err = retry.NewBudget(cfg.Retry.PoolBudget); err != nil {
+		logging.Logger.Printf("invalid pool retry budget, no budget is used: %s", err.Error())
+	}
 	alg.SetNodes(nodes)
-	lb.Algorithm = alg
+	lb.setAlgorithm(alg)
 
-	lb.StartPassiveHealthCheck(cfg.HealthCheck.Passive.Period, stop, done)
+	if cfg.HealthCheck.Passive.StartupTimeout > 0 {
+		lb.ServerPool.StartupHealthCheck(cfg.HealthCheck.Passive.StartupTimeout.Std())
+	}
+	lb.StartPassiveHealthCheck(cfg.HealthCheck.Passive.Period.Std(), stop, done)
 
-	return lb
+	return lb, nil
 }
@@ -1,9 +1,22 @@
 package app
 
 import (
+	"context"
 	"fmt"
-	"github.com/samanazadi/load-balancer/internal/models/node"
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/algorithm"
+	"github.com/samanazadi/load-balancer/pkg/checker"
+	"github.com/samanazadi/load-balancer/pkg/logging"
+	"github.com/samanazadi/load-balancer/pkg/models/node"
+	"github.com/samanazadi/load-balancer/pkg/retry"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLBSetNodeAlive(t *testing.T) {
@@ -29,3 +42,221 @@ func TestLBSetNodeAlive(t *testing.T) {
 		})
 	}
 }
+
+func newTestLB(t *testing.T, rc configs.Retry, backends ...*httptest.Server) *LoadBalancer {
+	logging.Logger = log.New(io.Discard, "", 0)
+	cfg := &configs.Config{Retry: rc}
+	cfg.HealthCheck.Active.MaxRetry = 100
+	cfg.HealthCheck.Passive.Period = configs.Seconds(3600)
+	for _, b := range backends {
+		cfg.Nodes = append(cfg.Nodes, configs.Node{URL: b.URL})
+	}
+	pol, err := retry.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alg := algorithm.NewRoundRobin()
+	lb, err := New(cfg, nil, alg, pol, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lb
+}
+
+func TestLBRetry(t *testing.T) {
+	var hits [3]int
+	newBackend := func(i, status int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			hits[i]++
+			body, _ := io.ReadAll(r.Body)
+			rw.WriteHeader(status)
+			fmt.Fprintf(rw, "%d:%s", i, body)
+		}))
+	}
+	down := newBackend(0, http.StatusOK)
+	down.Close()
+	unavailable := newBackend(1, http.StatusServiceUnavailable)
+	defer unavailable.Close()
+	ok := newBackend(2, http.StatusOK)
+	defer ok.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		wantStatus int
+		wantBody   string
+		wantHits   [3]int
+	}{
+		// connect failure on node 0, retryable status on node 1, success on node 2
+		{"Idempotent", http.MethodPut, http.StatusOK, "2:body", [3]int{0, 1, 1}},
+		// connect failure is retried, 503 is returned as is
+		{"NonIdempotent", http.MethodPost, http.StatusServiceUnavailable, "1:body", [3]int{0, 1, 0}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hits = [3]int{}
+			lb := newTestLB(t, configs.Retry{StatusCodes: []int{http.StatusServiceUnavailable}}, down, unavailable, ok)
+
+			rw := httptest.NewRecorder()
+			lb.ServeHTTP(rw, httptest.NewRequest(test.method, "/", strings.NewReader("body")))
+			if rw.Code != test.wantStatus || rw.Body.String() != test.wantBody {
+				t.Errorf("LoadBalancer.ServeHTTP(%s) = %d %q, want %d %q",
+					test.method, rw.Code, rw.Body.String(), test.wantStatus, test.wantBody)
+			}
+			if hits != test.wantHits {
+				t.Errorf("LoadBalancer.ServeHTTP(%s) hits = %v, want %v", test.method, hits, test.wantHits)
+			}
+		})
+	}
+}
+
+func TestLBRetryExhausted(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	down.Close()
+	lb := newTestLB(t, configs.Retry{}, down, down)
+
+	rw := httptest.NewRecorder()
+	lb.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rw.Code != http.StatusBadGateway {
+		t.Errorf("LoadBalancer.ServeHTTP(all nodes down) = %d, want %d", rw.Code, http.StatusBadGateway)
+	}
+}
+
+func TestNewNodeHealthCheck(t *testing.T) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	cfg := &configs.Config{Checker: configs.Checker{Name: "http", Params: map[string]any{"path": "/", "keyPhrase": "ok"}}}
+	cfg.HealthCheck.Passive.Timeout = configs.Seconds(1)
+
+	tests := []struct {
+		name        string
+		hc          *configs.NodeHealthCheck
+		wantTarget  string
+		wantChecker bool
+		wantErr     bool
+	}{
+		{"Default", nil, "http://10.0.0.1:8001", false, false},
+		{"Port", &configs.NodeHealthCheck{Port: 9000}, "http://10.0.0.1:9000", false, false},
+		{"URL", &configs.NodeHealthCheck{URL: "http://10.0.0.2:9000"}, "http://10.0.0.2:9000", false, false},
+		{"URLAndPort", &configs.NodeHealthCheck{URL: "https://mgmt", Port: 9443}, "https://mgmt:9443", false, false},
+		{"Path", &configs.NodeHealthCheck{Path: "/health"}, "http://10.0.0.1:8001", true, false},
+		{"Checker", &configs.NodeHealthCheck{Checker: &configs.Checker{Name: "tcp"}}, "http://10.0.0.1:8001", true, false},
+		{"InvalidChecker", &configs.NodeHealthCheck{Checker: &configs.Checker{Name: "udp"}}, "", false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n, err := newNode(cfg, configs.Node{URL: "http://10.0.0.1:8001", HealthCheck: test.hc}, nil)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("newNode(%+v) returned no error", test.hc)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newNode(%+v) returned error: %s", test.hc, err)
+			}
+			if got := n.HealthTarget().String(); got != test.wantTarget {
+				t.Errorf("newNode(%+v).HealthTarget() = %s, want %s", test.hc, got, test.wantTarget)
+			}
+			if (n.Checker != nil) != test.wantChecker {
+				t.Errorf("newNode(%+v).Checker = %v, want override %t", test.hc, n.Checker, test.wantChecker)
+			}
+		})
+	}
+
+	// path override keeps the other params of the global checker
+	n, _ := newNode(cfg, configs.Node{URL: "http://10.0.0.1:8001", HealthCheck: &configs.NodeHealthCheck{Path: "/health"}}, nil)
+	if chk, ok := n.Checker.(checker.HTTP); !ok || chk.Path != "/health" || chk.KeyPhrase != "ok" {
+		t.Errorf("newNode(path override).Checker = %+v, want path /health and keyPhrase ok", n.Checker)
+	}
+	if cfg.Checker.Params["path"] != "/" {
+		t.Errorf("newNode(path override) changed global checker params: %v", cfg.Checker.Params)
+	}
+}
+
+func TestNewInvalidNode(t *testing.T) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	cfg := &configs.Config{Checker: configs.Checker{Name: "tcp"}}
+	cfg.HealthCheck.Passive.Period = configs.Seconds(3600)
+	cfg.Nodes = []configs.Node{
+		{URL: "http://localhost:8001"},
+		{URL: "http://localhost:8002", HealthCheck: &configs.NodeHealthCheck{Checker: &configs.Checker{Name: "nope"}}},
+		{URL: "http://localhost:8003", HealthCheck: &configs.NodeHealthCheck{Path: "/health"}}, // tcp has no path
+	}
+
+	err := CheckNodes(cfg)
+	for _, path := range []string{"nodes[1].healthCheck", "nodes[2].healthCheck"} {
+		if err == nil || !strings.Contains(err.Error(), path+": ") {
+			t.Errorf("CheckNodes() = %v, want an error of %s", err, path)
+		}
+	}
+	if strings.Contains(fmt.Sprint(err), "nodes[0]") {
+		t.Errorf("CheckNodes() = %v, want no error of nodes[0]", err)
+	}
+
+	if lb, err := New(cfg, nil, algorithm.NewRoundRobin(), nil, nil, nil); lb != nil || err == nil {
+		t.Errorf("New(invalid nodes) = %v %v, want error", lb, err)
+	}
+	cfg.Nodes = cfg.Nodes[:1]
+	if err := CheckNodes(cfg); err != nil {
+		t.Errorf("CheckNodes(valid nodes) = %s", err)
+	}
+}
+
+// delayedChecker reports nodes on port 8001 healthy and delays checks of nodes on port 8003
+type delayedChecker struct {
+	delay time.Duration
+}
+
+func (c delayedChecker) Check(_ context.Context, u *url.URL) checker.Result {
+	if u.Port() == "8003" {
+		time.Sleep(c.delay)
+	}
+	return checker.Result{Healthy: u.Port() == "8001"}
+}
+
+func TestStartupHealthCheck(t *testing.T) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	tests := []struct {
+		state          string
+		startupTimeout configs.Duration
+		want           [3]bool // 8001 is healthy, 8002 is unhealthy, check of 8003 doesn't finish in time
+		wantUnknown    [3]bool
+	}{
+		{"", 0, [3]bool{true, true, true}, [3]bool{}},
+		{UpState, 0, [3]bool{true, true, true}, [3]bool{}},
+		{DownState, 0, [3]bool{false, false, false}, [3]bool{}},
+		{UnknownState, 0, [3]bool{false, false, false}, [3]bool{true, true, true}},
+		{UpState, configs.Duration(30 * time.Millisecond), [3]bool{true, false, true}, [3]bool{false, false, true}},
+		{DownState, configs.Duration(30 * time.Millisecond), [3]bool{true, false, false}, [3]bool{false, false, true}},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s%s", test.state, test.startupTimeout), func(t *testing.T) {
+			cfg := &configs.Config{}
+			cfg.HealthCheck.Passive.InitialState = test.state
+			cfg.HealthCheck.Passive.StartupTimeout = test.startupTimeout
+			var nodes []*node.Node
+			for _, u := range []string{"http://localhost:8001", "http://localhost:8002", "http://localhost:8003"} {
+				n, err := newNode(cfg, configs.Node{URL: u}, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				nodes = append(nodes, n)
+			}
+			pool := NewServerPool(nodes, delayedChecker{delay: 100 * time.Millisecond})
+			if test.startupTimeout > 0 {
+				start := time.Now()
+				finished := pool.StartupHealthCheck(test.startupTimeout.Std())
+				defer func() { <-finished }()
+				if elapsed := time.Since(start); elapsed > 80*time.Millisecond {
+					t.Errorf("ServerPool.StartupHealthCheck() took %s, want at most the timeout", elapsed)
+				}
+			}
+			for i, n := range pool.Nodes {
+				if h := n.Health(); h.Alive != test.want[i] || h.Unknown != test.wantUnknown[i] {
+					t.Errorf("node %s Health() = %+v, want alive = %t, unknown = %t",
+						n.URL, h, test.want[i], test.wantUnknown[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package app
+
+import (
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/logging"
+	"github.com/samanazadi/load-balancer/pkg/models/node"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// OutlierDetector ejects nodes whose responses are worse than expected or than the rest of the pool: consecutive
+// 5xx (transport errors included), consecutive gateway failures (502, 503, 504 and transport errors), and success
+// rate or latency deviating from the pool. Ejection time grows with each ejection of a node.
+type OutlierDetector struct {
+	Interval                  time.Duration // success rate and latency analysis
+	BaseEjectionTime          time.Duration
+	MaxEjectionTime           time.Duration
+	MaxEjectionPercent        int
+	Consecutive5xx            int // 0 to disable
+	ConsecutiveGatewayFailure int // 0 to disable
+	SuccessRateStdevFactor    float64
+	LatencyStdevFactor        float64
+	MinHosts                  int   // min nodes with enough requests for success rate and latency analysis
+	RequestVolume             int64 // min requests of a node in an interval for success rate and latency analysis
+
+	mux          sync.Mutex
+	stats        map[*node.Node]*outlierStats
+	lastAnalysis time.Time
+}
+
+type outlierStats struct {
+	consecutive5xx     int
+	consecutiveGateway int
+	ejections          int // recent ejections, each doubling the ejection time
+	ejected            bool
+	// current interval
+	total, success int64
+	latency        time.Duration
+}
+
+// NewOutlierDetector returns nil if outlier detection is disabled
+func NewOutlierDetector(cfg *configs.Config) *OutlierDetector {
+	oc := cfg.HealthCheck.OutlierDetection
+	if !oc.Enabled {
+		return nil
+	}
+	d := &OutlierDetector{
+		Interval:                  millisecondsWithDefault(oc.Interval, configs.DefaultOutlierInterval),
+		BaseEjectionTime:          millisecondsWithDefault(oc.BaseEjectionTime, configs.DefaultOutlierBaseEjectionTime),
+		MaxEjectionTime:           millisecondsWithDefault(oc.MaxEjectionTime, configs.DefaultOutlierMaxEjectionTime),
+		MaxEjectionPercent:        withDefault(oc.MaxEjectionPercent, configs.DefaultOutlierMaxEjectionPercent),
+		Consecutive5xx:            oc.Consecutive5xx,
+		ConsecutiveGatewayFailure: oc.ConsecutiveGatewayFailure,
+		SuccessRateStdevFactor:    oc.SuccessRateStdevFactor,
+		LatencyStdevFactor:        oc.LatencyStdevFactor,
+		MinHosts:                  withDefault(oc.MinHosts, configs.DefaultOutlierMinHosts),
+		RequestVolume:             int64(withDefault(oc.RequestVolume, configs.DefaultOutlierRequestVolume)),
+		stats:                     make(map[*node.Node]*outlierStats),
+	}
+	d.lastAnalysis = time.Now()
+	return d
+}
+
+func withDefault(v, def int) int {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
+
+// millisecondsWithDefault returns v milliseconds, or def milliseconds if v isn't positive
+func millisecondsWithDefault(v, def int) time.Duration {
+	return time.Millisecond * time.Duration(withDefault(v, def))
+}
+
+// Report records the outcome of a request sent to n, one of the nodes of the pool
+func (d *OutlierDetector) Report(nodes []*node.Node, n *node.Node, o node.Outcome) {
+	if d == nil {
+		return
+	}
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	st := d.stat(n)
+	st.total++
+	st.latency += o.Latency
+	switch {
+	case o.Err == nil && o.StatusCode < http.StatusInternalServerError:
+		st.success++
+		st.consecutive5xx, st.consecutiveGateway = 0, 0
+	case o.Err != nil || o.StatusCode == http.StatusBadGateway || o.StatusCode == http.StatusServiceUnavailable ||
+		o.StatusCode == http.StatusGatewayTimeout:
+		st.consecutive5xx++
+		st.consecutiveGateway++
+	default:
+		st.consecutive5xx++
+		st.consecutiveGateway = 0
+	}
+
+	if d.Consecutive5xx > 0 && st.consecutive5xx >= d.Consecutive5xx {
+		d.eject(nodes, n, "consecutive 5xx")
+	} else if d.ConsecutiveGatewayFailure > 0 && st.consecutiveGateway >= d.ConsecutiveGatewayFailure {
+		d.eject(nodes, n, "consecutive gateway failures")
+	}
+
+	if time.Since(d.lastAnalysis) >= d.Interval {
+		d.analyze(nodes)
+	}
+}
+
+func (d *OutlierDetector) stat(n *node.Node) *outlierStats {
+	st, ok := d.stats[n]
+	if !ok {
+		st = &outlierStats{}
+		d.stats[n] = st
+	}
+	return st
+}
+
+func (d *OutlierDetector) eject(nodes []*node.Node, n *node.Node, reason string) {
+	st := d.stat(n)
+	st.consecutive5xx, st.consecutiveGateway = 0, 0
+	if n.IsEjected() {
+		return
+	}
+
+	ejected := 0
+	for _, nn := range nodes {
+		if nn.IsEjected() {
+			ejected++
+		}
+	}
+	if ejected > 0 && (ejected+1)*100 > d.MaxEjectionPercent*len(nodes) {
+		logging.Logger.Printf("outlier detection, %s: not ejected (%s), max ejection percent reached", n.URL, reason)
+		return
+	}
+
+	st.ejections++
+	duration := ejectionTime(d.BaseEjectionTime, d.MaxEjectionTime, st.ejections)
+	st.ejected = true
+	n.Eject(time.Now().Add(duration))
+	logging.Logger.Printf("outlier detection, %s: ejected for %s (%s)", n.URL, duration, reason)
+}
+
+// ejectionTime returns base << (ejections-1), capped at max before the shift can overflow
+func ejectionTime(base, max time.Duration, ejections int) time.Duration {
+	shift := ejections - 1
+	if shift < 0 {
+		shift = 0
+	}
+	if base <= 0 || shift >= 63 || base > max>>shift {
+		return max
+	}
+	return base << shift
+}
+
+// analyze ejects nodes with success rate or latency deviating from the pool and starts a new interval. Stats of nodes
+// which left the pool are dropped.
+func (d *OutlierDetector) analyze(nodes []*node.Node) {
+	d.lastAnalysis = time.Now()
+
+	pool := make(map[*node.Node]bool, len(nodes))
+	for _, n := range nodes {
+		pool[n] = true
+	}
+	for n := range d.stats {
+		if !pool[n] {
+			delete(d.stats, n)
+		}
+	}
+
+	var candidates []*node.Node
+	for _, n := range nodes {
+		st := d.stat(n)
+		if st.ejected && !n.IsEjected() {
+			st.ejected = false
+			logging.Logger.Printf("outlier detection, %s: returned to rotation", n.URL)
+		} else if !st.ejected && st.ejections > 0 {
+			st.ejections-- // decay
+		}
+		if !st.ejected && st.total >= d.RequestVolume {
+			candidates = append(candidates, n)
+		}
+	}
+
+	if len(candidates) >= d.MinHosts {
+		if d.SuccessRateStdevFactor > 0 {
+			rates := make([]float64, len(candidates))
+			for i, n := range candidates {
+				st := d.stats[n]
+				rates[i] = float64(st.success) / float64(st.total)
+			}
+			mean, stdev := meanStdev(rates)
+			for i, n := range candidates {
+				if rates[i] < mean-d.SuccessRateStdevFactor*stdev {
+					d.eject(nodes, n, "low success rate")
+				}
+			}
+		}
+		if d.LatencyStdevFactor > 0 {
+			latencies := make([]float64, len(candidates))
+			for i, n := range candidates {
+				st := d.stats[n]
+				latencies[i] = float64(st.latency) / float64(st.total)
+			}
+			mean, stdev := meanStdev(latencies)
+			for i, n := range candidates {
+				if latencies[i] > mean+d.LatencyStdevFactor*stdev {
+					d.eject(nodes, n, "high latency")
+				}
+			}
+		}
+	}
+
+	for _, st := range d.stats {
+		st.total, st.success, st.latency = 0, 0, 0
+	}
+}
+
+func meanStdev(values []float64) (mean, stdev float64) {
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	for _, v := range values {
+		stdev += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(stdev / float64(len(values)))
+}
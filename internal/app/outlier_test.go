@@ -0,0 +1,168 @@
+package app
+
+import (
+	"errors"
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/logging"
+	"github.com/samanazadi/load-balancer/pkg/models/node"
+	"io"
+	"log"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newOutlierDetector(oc configs.OutlierDetection) (*OutlierDetector, []*node.Node) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	oc.Enabled = true
+	cfg := &configs.Config{}
+	cfg.HealthCheck.OutlierDetection = oc
+	nodes, _ := node.CreateFakeNodes()
+	for _, n := range nodes {
+		n.SetAlive(true)
+	}
+	return NewOutlierDetector(cfg), nodes
+}
+
+func TestOutlierDisabled(t *testing.T) {
+	d := NewOutlierDetector(&configs.Config{})
+	if d != nil {
+		t.Fatalf("NewOutlierDetector(disabled) != nil")
+	}
+	d.Report(nil, nil, node.Outcome{}) // no panic
+}
+
+func TestOutlierConsecutive5xx(t *testing.T) {
+	d, nodes := newOutlierDetector(configs.OutlierDetection{Consecutive5xx: 3, MaxEjectionPercent: 50})
+
+	d.Report(nodes, nodes[0], node.Outcome{StatusCode: http.StatusInternalServerError})
+	d.Report(nodes, nodes[0], node.Outcome{StatusCode: http.StatusOK}) // resets
+	d.Report(nodes, nodes[0], node.Outcome{StatusCode: http.StatusInternalServerError})
+	d.Report(nodes, nodes[0], node.Outcome{Err: errors.New("connection reset")})
+	if nodes[0].IsEjected() {
+		t.Fatalf("node ejected after 2 consecutive 5xx")
+	}
+	d.Report(nodes, nodes[0], node.Outcome{StatusCode: http.StatusInternalServerError})
+	if !nodes[0].IsEjected() {
+		t.Errorf("node not ejected after 3 consecutive 5xx")
+	}
+}
+
+func TestOutlierConsecutiveGatewayFailure(t *testing.T) {
+	d, nodes := newOutlierDetector(configs.OutlierDetection{ConsecutiveGatewayFailure: 2})
+
+	d.Report(nodes, nodes[0], node.Outcome{StatusCode: http.StatusBadGateway})
+	d.Report(nodes, nodes[0], node.Outcome{StatusCode: http.StatusInternalServerError}) // not a gateway failure
+	d.Report(nodes, nodes[0], node.Outcome{StatusCode: http.StatusServiceUnavailable})
+	if nodes[0].IsEjected() {
+		t.Fatalf("node ejected after non-consecutive gateway failures")
+	}
+	d.Report(nodes, nodes[0], node.Outcome{Err: errors.New("connection refused")})
+	if !nodes[0].IsEjected() {
+		t.Errorf("node not ejected after 2 consecutive gateway failures")
+	}
+}
+
+func TestOutlierMaxEjectionPercent(t *testing.T) {
+	d, nodes := newOutlierDetector(configs.OutlierDetection{Consecutive5xx: 1, MaxEjectionPercent: 50})
+	for _, n := range nodes {
+		d.Report(nodes, n, node.Outcome{StatusCode: http.StatusInternalServerError})
+	}
+	ejected := 0
+	for _, n := range nodes {
+		if n.IsEjected() {
+			ejected++
+		}
+	}
+	if ejected != 2 {
+		t.Errorf("%d of 4 nodes ejected with max ejection percent 50, want 2", ejected)
+	}
+}
+
+func TestOutlierEjectionTime(t *testing.T) {
+	d, nodes := newOutlierDetector(configs.OutlierDetection{Consecutive5xx: 1, MaxEjectionPercent: 100,
+		BaseEjectionTime: 10, MaxEjectionTime: 50})
+	for i, want := range []time.Duration{10, 20, 40, 50} {
+		start := time.Now()
+		d.Report(nodes, nodes[0], node.Outcome{StatusCode: http.StatusInternalServerError})
+		for nodes[0].IsEjected() {
+			time.Sleep(time.Millisecond)
+		}
+		if got := time.Since(start); got < want*time.Millisecond {
+			t.Errorf("ejection %d lasted %s, want %dms", i+1, got, want)
+		}
+	}
+}
+
+func TestEjectionTime(t *testing.T) {
+	tests := []struct {
+		base, max time.Duration
+		ejections int
+		want      time.Duration
+	}{
+		{time.Second, time.Minute, 1, time.Second},
+		{time.Second, time.Minute, 3, 4 * time.Second},
+		{time.Second, time.Minute, 7, time.Minute},
+		{time.Second, time.Minute, 40, time.Minute},  // the shift would overflow
+		{time.Second, time.Minute, 100, time.Minute}, // beyond the bits of a duration
+		{time.Minute, time.Second, 1, time.Second},
+	}
+	for _, test := range tests {
+		if got := ejectionTime(test.base, test.max, test.ejections); got != test.want {
+			t.Errorf("ejectionTime(%s, %s, %d) = %s, want %s", test.base, test.max, test.ejections, got, test.want)
+		}
+	}
+}
+
+func TestOutlierRemovedNodes(t *testing.T) {
+	d, nodes := newOutlierDetector(configs.OutlierDetection{Consecutive5xx: 5, MaxEjectionPercent: 50})
+	for _, n := range nodes {
+		d.Report(nodes, n, node.Outcome{StatusCode: http.StatusOK})
+	}
+	d.analyze(nodes[:2])
+	if len(d.stats) != 2 || d.stats[nodes[0]] == nil || d.stats[nodes[1]] == nil {
+		t.Errorf("OutlierDetector.stats has %d nodes after 2 nodes are left, want 2", len(d.stats))
+	}
+}
+
+func TestOutlierSuccessRate(t *testing.T) {
+	d, nodes := newOutlierDetector(configs.OutlierDetection{SuccessRateStdevFactor: 1, MinHosts: 4,
+		RequestVolume: 10, MaxEjectionPercent: 50, Interval: 1})
+	d.Interval = time.Hour // analyze manually
+
+	for i, n := range nodes {
+		for j := 0; j < 10; j++ {
+			status := http.StatusOK
+			if i == 3 && j%2 == 0 { // 50% success rate
+				status = http.StatusInternalServerError
+			}
+			d.Report(nodes, n, node.Outcome{StatusCode: status})
+		}
+	}
+	d.analyze(nodes)
+	for i, n := range nodes {
+		if n.IsEjected() != (i == 3) {
+			t.Errorf("node %d ejected = %t after success rate analysis", i, n.IsEjected())
+		}
+	}
+}
+
+func TestOutlierLatency(t *testing.T) {
+	d, nodes := newOutlierDetector(configs.OutlierDetection{LatencyStdevFactor: 1, MinHosts: 4,
+		RequestVolume: 1, MaxEjectionPercent: 50})
+	d.Interval = time.Hour // analyze manually
+
+	for i, n := range nodes {
+		latency := 10 * time.Millisecond
+		if i == 1 {
+			latency = time.Second
+		}
+		d.Report(nodes, n, node.Outcome{StatusCode: http.StatusOK, Latency: latency})
+	}
+	d.analyze(nodes)
+	for i, n := range nodes {
+		if n.IsEjected() != (i == 1) {
+			t.Errorf("node %d ejected = %t after latency analysis", i, n.IsEjected())
+		}
+	}
+}
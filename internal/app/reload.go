@@ -0,0 +1,120 @@
+package app
+
+import (
+	"fmt"
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/algorithm"
+	"github.com/samanazadi/load-balancer/pkg/checker"
+	"github.com/samanazadi/load-balancer/pkg/logging"
+	"github.com/samanazadi/load-balancer/pkg/models/node"
+	"net/url"
+	"reflect"
+)
+
+// Reload applies the nodes, the algorithm and the checker of cfg while serving. Unchanged nodes keep their state:
+// health, circuit, ejection, slow start and in-flight requests. A node whose weight is the only change keeps its
+// state too. Removed nodes get no new requests while their in-flight ones finish. If cfg is invalid nothing changes.
+// Other settings are applied on restart.
+func (lb *LoadBalancer) Reload(cfg *configs.Config) error {
+	lb.mux.Lock()
+	defer lb.mux.Unlock()
+	old := lb.cfg
+
+	// the old config along the reloaded sections
+	next := *old
+	next.Nodes, next.Algorithm, next.Checker = cfg.Nodes, cfg.Algorithm, cfg.Checker
+
+	chk, err := checker.New(&next)
+	if err != nil {
+		return fmt.Errorf("invalid checker: %s", err.Error())
+	}
+	checkerChanged := !reflect.DeepEqual(old.Checker, cfg.Checker)
+	alg := lb.Algorithm()
+	algorithmChanged := !reflect.DeepEqual(old.Algorithm, cfg.Algorithm)
+	if algorithmChanged {
+		if alg, err = algorithm.New(&next); err != nil {
+			return fmt.Errorf("invalid algorithm: %s", err.Error())
+		}
+	}
+
+	current := make(map[string]*node.Node)
+	for _, n := range lb.ServerPool.List() {
+		current[n.URL.Host] = n
+	}
+	nodes := make([]*node.Node, 0, len(cfg.Nodes))
+	nodeCfgs := make(map[*node.Node]configs.Node, len(cfg.Nodes))
+	weights := make(map[*node.Node]int)
+	seen := make(map[string]bool, len(cfg.Nodes))
+	var added, changed int
+	for _, nodeCfg := range cfg.Nodes {
+		u, err := url.Parse(nodeCfg.URL)
+		if err != nil || nodeCfg.URL == "" {
+			return fmt.Errorf("cannot parse node URL: %s", nodeCfg.URL)
+		}
+		if seen[u.Host] {
+			return fmt.Errorf("%w: %s", ErrNodeExists, u.Host)
+		}
+		seen[u.Host] = true
+		n, ok := current[u.Host]
+		delete(current, u.Host)
+		if ok && sameNode(lb.nodeCfgs[n], nodeCfg, checkerChanged) {
+			if lb.nodeCfgs[n].Weight != nodeCfg.Weight {
+				weights[n] = nodeCfg.Weight
+			}
+		} else {
+			if n, err = newNode(&next, nodeCfg, lb); err != nil {
+				return fmt.Errorf("invalid node %s: %s", nodeCfg.URL, err.Error())
+			}
+			if ok {
+				changed++
+			} else {
+				added++
+			}
+		}
+		nodes = append(nodes, n)
+		nodeCfgs[n] = nodeCfg
+	}
+
+	// valid, swap
+	for n, weight := range weights {
+		n.SetWeight(weight)
+	}
+	alg.SetNodes(nodes)
+	if algorithmChanged {
+		lb.setAlgorithm(alg)
+	}
+	lb.ServerPool.SetNodes(nodes)
+	rise, fall := configs.DefaultRise, configs.DefaultFall
+	if cfg.Checker.Rise > 0 {
+		rise = cfg.Checker.Rise
+	}
+	if cfg.Checker.Fall > 0 {
+		fall = cfg.Checker.Fall
+	}
+	lb.ServerPool.SetChecker(chk, rise, fall)
+	lb.cfg, lb.nodeCfgs = &next, nodeCfgs
+
+	logging.Logger.Printf("config reloaded: %d nodes added, %d changed, %d removed, algorithm changed: %t, "+
+		"checker changed: %t", added, changed, len(current), algorithmChanged, checkerChanged)
+	for _, n := range current {
+		logging.Logger.Printf("node removed: %s", n.URL)
+	}
+	restart := *cfg
+	restart.Nodes, restart.Algorithm, restart.Checker = old.Nodes, old.Algorithm, old.Checker
+	if !reflect.DeepEqual(restart, *old) {
+		logging.Logger.Printf("config reloaded partially, restart to apply changes other than nodes, algorithm " +
+			"and checker")
+	}
+	return nil
+}
+
+// sameNode reports whether a node created from old can serve as one created from cfg, updating its weight
+func sameNode(old, cfg configs.Node, checkerChanged bool) bool {
+	old.Weight, cfg.Weight = 0, 0
+	if !reflect.DeepEqual(old, cfg) {
+		return false
+	}
+	// a node checker overriding only the path is built from the checker of the config
+	hc := cfg.HealthCheck
+	return hc == nil || hc.Checker != nil || hc.Path == "" || !checkerChanged
+}
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"errors"
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/algorithm"
+	"github.com/samanazadi/load-balancer/pkg/checker"
+	"github.com/samanazadi/load-balancer/pkg/logging"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newReloadConfig(algorithmName string, nodes ...configs.Node) *configs.Config {
+	cfg := &configs.Config{Nodes: nodes}
+	cfg.HealthCheck.Passive.Period = configs.Seconds(3600)
+	cfg.Algorithm.Name = algorithmName
+	cfg.Checker.Name = checker.TCPType
+	return cfg
+}
+
+func TestReload(t *testing.T) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	cfg := newReloadConfig(algorithm.RRType,
+		configs.Node{URL: "http://localhost:8001"},
+		configs.Node{URL: "http://localhost:8002"},
+		configs.Node{URL: "http://localhost:8003"})
+	lb, err := New(cfg, nil, algorithm.NewRoundRobin(), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, changed := lb.ServerPool.Node("localhost:8001"), lb.ServerPool.Node("localhost:8002")
+	kept.SetAlive(false)
+
+	// weight only change keeps the node, proxy protocol change replaces it
+	next := newReloadConfig(algorithm.WRRType,
+		configs.Node{URL: "http://localhost:8001", Weight: 3},
+		configs.Node{URL: "http://localhost:8002", ProxyProtocol: 1},
+		configs.Node{URL: "http://localhost:8004"})
+	next.Checker.Rise = 2
+	if err := lb.Reload(next); err != nil {
+		t.Fatalf("LoadBalancer.Reload() = %s, want no error", err)
+	}
+
+	nodes := lb.ServerPool.List()
+	if len(nodes) != 3 || nodes[0] != kept || nodes[1] == changed || nodes[2].URL.Host != "localhost:8004" {
+		t.Fatalf("LoadBalancer.Reload() nodes = %v, want 8001 kept, 8002 replaced and 8004 added", nodes)
+	}
+	if kept.IsAlive() || kept.Weight() != 3 {
+		t.Errorf("kept node alive = %t, weight = %d, want dead with weight 3", kept.IsAlive(), kept.Weight())
+	}
+	if lb.ServerPool.Node("localhost:8003") != nil {
+		t.Errorf("removed node is still in the pool")
+	}
+	if _, ok := lb.Algorithm().(*algorithm.WeightedRoundRobin); !ok {
+		t.Errorf("LoadBalancer.Algorithm() = %T after reload, want WeightedRoundRobin", lb.Algorithm())
+	}
+	if lb.ServerPool.Rise != 2 {
+		t.Errorf("ServerPool.Rise = %d after reload, want 2", lb.ServerPool.Rise)
+	}
+	for i := 0; i < 10; i++ {
+		n := lb.Algorithm().GetNextEligibleNode(httptest.NewRequest(http.MethodGet, "/", nil))
+		if n == nil || n == kept || n.URL.Host == "localhost:8003" {
+			t.Fatalf("GetNextEligibleNode() = %v after reload, want an alive node of the new config", n)
+		}
+	}
+}
+
+func TestReloadInvalid(t *testing.T) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	cfg := newReloadConfig(algorithm.RRType, configs.Node{URL: "http://localhost:8001"})
+	alg := algorithm.NewRoundRobin()
+	lb, err := New(cfg, nil, alg, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := lb.ServerPool.Node("localhost:8001")
+
+	tests := []struct {
+		name string
+		cfg  *configs.Config
+	}{
+		{"Algorithm", newReloadConfig("invalid", configs.Node{URL: "http://localhost:8002"})},
+		{"Checker", func() *configs.Config {
+			c := newReloadConfig(algorithm.RRType, configs.Node{URL: "http://localhost:8002"})
+			c.Checker.Name = "invalid"
+			return c
+		}()},
+		{"NodeURL", newReloadConfig(algorithm.RRType, configs.Node{})},
+		{"ProxyProtocol", newReloadConfig(algorithm.RRType, configs.Node{URL: "http://localhost:8002", ProxyProtocol: 3})},
+		{"Duplicate", newReloadConfig(algorithm.RRType,
+			configs.Node{URL: "http://localhost:8002"}, configs.Node{URL: "http://localhost:8002", Weight: 2})},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := lb.Reload(test.cfg); err == nil {
+				t.Fatalf("LoadBalancer.Reload(invalid %s) = nil, want error", test.name)
+			}
+			nodes := lb.ServerPool.List()
+			next := lb.Algorithm().GetNextEligibleNode(httptest.NewRequest(http.MethodGet, "/", nil))
+			if len(nodes) != 1 || nodes[0] != n || lb.Algorithm() != alg || next != n {
+				t.Errorf("LoadBalancer.Reload(invalid %s) changed the load balancer", test.name)
+			}
+		})
+	}
+	if err := lb.Reload(tests[len(tests)-1].cfg); !errors.Is(err, ErrNodeExists) {
+		t.Errorf("LoadBalancer.Reload(duplicate nodes) = %v, want %s", err, ErrNodeExists)
+	}
+}
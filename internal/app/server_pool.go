@@ -1,38 +1,197 @@
 package app
 
 import (
-	"github.com/samanazadi/load-balancer/internal/checker"
-	"github.com/samanazadi/load-balancer/internal/models/node"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/checker"
 	"github.com/samanazadi/load-balancer/pkg/logging"
+	"github.com/samanazadi/load-balancer/pkg/models/node"
+	"github.com/samanazadi/load-balancer/pkg/retry"
+	"math/rand"
+	"net/http"
 	"net/url"
 	"sync"
 	"time"
 )
 
+var (
+	ErrNodeExists   = errors.New("node already exists")
+	ErrNodeNotFound = errors.New("node not found")
+)
+
 // ServerPool manage servers
 type ServerPool struct {
-	Nodes             []*node.Node
+	Nodes             []*node.Node // replaced, never modified in place, on changes; read with List
 	ConnectionChecker checker.ConnectionChecker
+	Rise              int                               // consecutive successful checks which bring a dead node up
+	Fall              int                               // consecutive failed checks which take an alive node down
+	UnhealthyPeriod   time.Duration                     // passive health check period of dead nodes, the normal period if 0
+	Jitter            float64                           // random fraction of the period added or subtracted
+	checkSlots        chan struct{}                     // limits concurrent passive health checks, nil for unlimited
+	RetryBudget       *retry.Budget                     // nil for unlimited retries
+	Outliers          *OutlierDetector                  // nil if disabled
+	mux               sync.RWMutex                      // for protecting Nodes, ConnectionChecker, Rise, Fall and schedules
+	period            time.Duration                     // passive health check period
+	ctx               context.Context                   // canceled when passive health check stops
+	cancel            context.CancelFunc                // stops passive health check, nil if not started
+	schedules         map[*node.Node]context.CancelFunc // stops the passive health check of each node
+	scheduled         *sync.WaitGroup                   // running passive health check schedules of the current start
 }
 
-func (p *ServerPool) passiveHealthCheck() {
-	var wg sync.WaitGroup
+// List returns the nodes. The returned slice must not be modified.
+func (p *ServerPool) List() []*node.Node {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	return p.Nodes
+}
+
+// Node returns the node whose host (like "localhost:8001") is host, or nil
+func (p *ServerPool) Node(host string) *node.Node {
+	for _, n := range p.List() {
+		if n.URL.Host == host {
+			return n
+		}
+	}
+	return nil
+}
+
+// AddNode adds n and starts its passive health check. It returns the new nodes.
+func (p *ServerPool) AddNode(n *node.Node) ([]*node.Node, error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	for _, existing := range p.Nodes {
+		if existing.URL.Host == n.URL.Host {
+			return nil, fmt.Errorf("%w: %s", ErrNodeExists, n.URL.Host)
+		}
+	}
+	nodes := make([]*node.Node, 0, len(p.Nodes)+1)
+	nodes = append(append(nodes, p.Nodes...), n)
+	p.Nodes = nodes
+	p.watch(n)
+	return nodes, nil
+}
+
+// RemoveNode removes the node whose host is host and stops its passive health check. It returns the removed node
+// and the new nodes.
+func (p *ServerPool) RemoveNode(host string) (*node.Node, []*node.Node, error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	for i, n := range p.Nodes {
+		if n.URL.Host == host {
+			nodes := make([]*node.Node, 0, len(p.Nodes)-1)
+			nodes = append(append(nodes, p.Nodes[:i]...), p.Nodes[i+1:]...)
+			p.Nodes = nodes
+			p.unwatch(n)
+			return n, nodes, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%w: %s", ErrNodeNotFound, host)
+}
+
+// SetNodes replaces the nodes, starting the passive health check of the added nodes and stopping the removed ones.
+// nodes must not be modified afterwards.
+func (p *ServerPool) SetNodes(nodes []*node.Node) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	kept := make(map[*node.Node]bool, len(nodes))
+	for _, n := range nodes {
+		kept[n] = true
+	}
 	for _, n := range p.Nodes {
+		if !kept[n] {
+			p.unwatch(n)
+		}
+		delete(kept, n)
+	}
+	for _, n := range nodes {
+		if kept[n] {
+			p.watch(n)
+		}
+	}
+	p.Nodes = nodes
+}
+
+// SetChecker replaces the passive health checker and its rise and fall while checks are running
+func (p *ServerPool) SetChecker(chk checker.ConnectionChecker, rise, fall int) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.ConnectionChecker, p.Rise, p.Fall = chk, rise, fall
+}
+
+// passiveHealthCheck checks every node once
+func (p *ServerPool) passiveHealthCheck(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, n := range p.List() {
 		wg.Add(1)
 		n := n
 		go func() {
 			defer wg.Done()
-			alive := p.ConnectionChecker.Check(n.URL)
-			if alive != n.IsAlive() {
-				logging.Logger.Printf("passive health check, %s: %s -> %s",
-					n.URL.String(), aliveToString(n.IsAlive()), aliveToString(alive))
-			}
-			n.SetAlive(alive)
+			p.checkNode(ctx, n)
 		}()
 	}
 	wg.Wait()
 }
 
+// StartupHealthCheck checks every node once before serving, waiting at most timeout. Checks which don't finish in
+// time decide the state of their nodes when they finish. The returned channel is closed when all checks finish.
+func (p *ServerPool) StartupHealthCheck(timeout time.Duration) <-chan struct{} {
+	logging.Logger.Printf("startup health check is starting...")
+	finished := make(chan struct{})
+	go func() {
+		p.passiveHealthCheck(context.Background())
+		close(finished)
+	}()
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-finished:
+	case <-t.C:
+		logging.Logger.Printf("startup health check timed out after %s", timeout)
+	}
+
+	nodes, alive := p.List(), 0
+	for _, n := range nodes {
+		if n.IsAlive() {
+			alive++
+		}
+	}
+	logging.Logger.Printf("startup health check completed, %d of %d nodes are up", alive, len(nodes))
+	return finished
+}
+
+// checkNode runs a passive health check of n, waiting for a free slot if concurrent checks are limited. The result
+// of a check canceled by ctx is dropped.
+func (p *ServerPool) checkNode(ctx context.Context, n *node.Node) {
+	if p.checkSlots != nil {
+		select {
+		case p.checkSlots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		defer func() { <-p.checkSlots }()
+	}
+	p.mux.RLock()
+	chk, rise, fall := p.ConnectionChecker, p.Rise, p.Fall
+	p.mux.RUnlock()
+	if n.Checker != nil {
+		chk = n.Checker
+	}
+	res := chk.Check(ctx, n.HealthTarget())
+	if ctx.Err() != nil {
+		return
+	}
+	if !res.Healthy {
+		logging.Logger.Printf("passive health check failed, %s: %s", n.URL.String(), res)
+	}
+	if n.ApplyCheck(res, rise, fall) {
+		h := n.Health()
+		logging.Logger.Printf("passive health check, %s: %s -> %s (transitions: %d)",
+			n.URL.String(), aliveToString(!h.Alive), aliveToString(h.Alive), h.Transitions)
+	}
+}
+
 func aliveToString(alive bool) string {
 	if alive {
 		return "up"
@@ -40,27 +199,104 @@ func aliveToString(alive bool) string {
 	return "down"
 }
 
-func (p *ServerPool) StartPassiveHealthCheck(period int, stop <-chan bool, done chan<- bool) {
+// SetMaxConcurrentChecks limits passive health checks running at the same time, 0 for unlimited.
+// It must be called before starting passive health check.
+func (p *ServerPool) SetMaxConcurrentChecks(n int) {
+	p.checkSlots = nil
+	if n > 0 {
+		p.checkSlots = make(chan struct{}, n)
+	}
+}
+
+// StartPassiveHealthCheck checks every node on its own schedule. The first check of each node is at a random point
+// of the first period, so nodes aren't checked at the same instant. Stopping cancels the running checks. Starting
+// again stops the schedules of the previous start first, which then signals its done channel.
+func (p *ServerPool) StartPassiveHealthCheck(period time.Duration, stop <-chan bool, done chan<- bool) {
+	logging.Logger.Printf("passive health check daemon started")
+	ctx, cancel := context.WithCancel(context.Background())
+	scheduled := new(sync.WaitGroup)
+	p.mux.Lock()
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.period = period
+	p.ctx, p.cancel = ctx, cancel
+	p.scheduled = scheduled
+	p.schedules = make(map[*node.Node]context.CancelFunc, len(p.Nodes))
+	for _, n := range p.Nodes {
+		p.watch(n)
+	}
+	p.mux.Unlock()
 	go func() {
-		logging.Logger.Printf("passive health check daemon started")
-		period := time.Second * time.Duration(period)
-		t := time.NewTicker(period)
-		for {
-			select {
-			case <-t.C:
-				logging.Logger.Println("passive health check is starting...")
-				p.passiveHealthCheck()
-				logging.Logger.Println("passive health check completed")
-			case <-stop:
-				done <- true
-				return
-			}
+		select {
+		case <-stop:
+		case <-ctx.Done(): // started again
+		}
+		p.mux.Lock()
+		cancel()
+		if p.ctx == ctx {
+			p.cancel, p.schedules = nil, nil
+		}
+		p.mux.Unlock()
+		scheduled.Wait()
+		if done != nil {
+			done <- true
 		}
 	}()
 }
 
+// watch starts the passive health check schedule of n if passive health check is started. p.mux must be held.
+func (p *ServerPool) watch(n *node.Node) {
+	if p.schedules == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(p.ctx)
+	p.schedules[n] = cancel
+	scheduled := p.scheduled
+	scheduled.Add(1)
+	go func(period time.Duration) {
+		defer scheduled.Done()
+		p.schedule(ctx, n, period)
+	}(p.period)
+}
+
+// unwatch stops the passive health check schedule of n. p.mux must be held.
+func (p *ServerPool) unwatch(n *node.Node) {
+	if cancel, ok := p.schedules[n]; ok {
+		cancel()
+		delete(p.schedules, n)
+	}
+}
+
+// schedule checks n every period, or every UnhealthyPeriod while it is down, until ctx is canceled
+func (p *ServerPool) schedule(ctx context.Context, n *node.Node, period time.Duration) {
+	t := time.NewTimer(time.Duration(rand.Int63n(int64(period))))
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			p.checkNode(ctx, n)
+			next := period
+			if !n.IsAlive() && p.UnhealthyPeriod > 0 {
+				next = p.UnhealthyPeriod
+			}
+			t.Reset(jitter(next, p.Jitter))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// jitter randomly adds or subtracts up to fraction of d
+func jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return d
+	}
+	return d + time.Duration((rand.Float64()*2-1)*fraction*float64(d))
+}
+
 func (p *ServerPool) SetNodeAlive(nodeURL *url.URL, alive bool) {
-	for _, n := range p.Nodes {
+	for _, n := range p.List() {
 		if n.URL.String() == nodeURL.String() {
 			n.SetAlive(alive)
 			return
@@ -68,9 +304,22 @@ func (p *ServerPool) SetNodeAlive(nodeURL *url.URL, alive bool) {
 	}
 }
 
+// hasUntriedNode reports whether an alive node which hasn't served r yet exists
+func (p *ServerPool) hasUntriedNode(r *http.Request) bool {
+	for _, n := range p.List() {
+		if n.IsAlive() && n.IsEnabled() && !n.IsDraining() && !n.IsEjected() && n.Breaker.Ready() &&
+			!retry.Tried(r, n.URL) {
+			return true
+		}
+	}
+	return false
+}
+
 func NewServerPool(nodes []*node.Node, chk checker.ConnectionChecker) ServerPool {
 	return ServerPool{
 		Nodes:             nodes,
 		ConnectionChecker: chk,
+		Rise:              configs.DefaultRise,
+		Fall:              configs.DefaultFall,
 	}
 }
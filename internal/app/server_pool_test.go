@@ -1,10 +1,18 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"github.com/samanazadi/load-balancer/internal/models/node"
+	"github.com/samanazadi/load-balancer/pkg/checker"
+	"github.com/samanazadi/load-balancer/pkg/logging"
+	"github.com/samanazadi/load-balancer/pkg/models/node"
+	"io"
+	"log"
 	"net/url"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestSPSetNodeAlive(t *testing.T) {
@@ -57,3 +65,248 @@ func TestNewServerPool(t *testing.T) {
 		}
 	}
 }
+
+// scriptedChecker returns the scripted results of each node in order
+type scriptedChecker struct {
+	mux     sync.Mutex
+	results map[string][]bool
+}
+
+func (c *scriptedChecker) Check(_ context.Context, u *url.URL) checker.Result {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	r := c.results[u.String()]
+	healthy := r[0]
+	c.results[u.String()] = r[1:]
+	return checker.Result{Healthy: healthy}
+}
+
+func TestPassiveHealthCheckFlapping(t *testing.T) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	nodes, _ := node.CreateFakeNodes()
+	nodes = nodes[:2]
+	nodes[0].SetAlive(true)
+	nodes[1].SetAlive(false)
+	chk := &scriptedChecker{results: map[string][]bool{
+		// alive node with dropped probes
+		nodes[0].URL.String(): {false, true, false, false, true, false, false, false},
+		// dead node with lucky probes
+		nodes[1].URL.String(): {true, false, true, true, false, true, true, true},
+	}}
+	pool := NewServerPool(nodes, chk)
+	pool.Rise, pool.Fall = 3, 3
+	transitions := []int{nodes[0].Health().Transitions, nodes[1].Health().Transitions}
+
+	wants := [][2]bool{
+		{true, false}, {true, false}, {true, false}, {true, false}, {true, false}, {true, false}, {true, false},
+		{false, true},
+	}
+	for i, want := range wants {
+		pool.passiveHealthCheck(context.Background())
+		if got := [2]bool{nodes[0].IsAlive(), nodes[1].IsAlive()}; got != want {
+			t.Fatalf("check %d: alive = %v, want %v", i+1, got, want)
+		}
+	}
+	for i, n := range nodes {
+		if h := n.Health(); h.Transitions != transitions[i]+1 || h.LastTransition.IsZero() {
+			t.Errorf("node %d Health() = %+v, want 1 transition", i, h)
+		}
+		if checks := n.Checks(); len(checks) != len(wants) || checks[len(checks)-1].Healthy != wants[7][i] {
+			t.Errorf("node %d Checks() = %v, want %d results ending with the last check", i, checks, len(wants))
+		}
+	}
+}
+
+// countingChecker counts checks per node and the max number of checks running at the same time
+type countingChecker struct {
+	mux               sync.Mutex
+	delay             time.Duration
+	checks            map[string]int
+	running, maxPeers int
+}
+
+func (c *countingChecker) Check(_ context.Context, u *url.URL) checker.Result {
+	c.mux.Lock()
+	c.checks[u.String()]++
+	c.running++
+	if c.running > c.maxPeers {
+		c.maxPeers = c.running
+	}
+	c.mux.Unlock()
+	time.Sleep(c.delay)
+	c.mux.Lock()
+	c.running--
+	c.mux.Unlock()
+	return checker.Result{Healthy: u.Port() != "8002"}
+}
+
+func TestStartPassiveHealthCheck(t *testing.T) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	nodes, _ := node.CreateFakeNodes()
+	nodes = nodes[:2] // localhost:8001 stays up, localhost:8002 goes down
+	chk := &countingChecker{checks: map[string]int{}}
+	pool := NewServerPool(nodes, chk)
+	pool.UnhealthyPeriod = 5 * time.Millisecond
+	pool.Jitter = 0.2
+
+	stop, done := make(chan bool), make(chan bool)
+	pool.StartPassiveHealthCheck(50*time.Millisecond, stop, done)
+	time.Sleep(200 * time.Millisecond)
+	stop <- true
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("passive health check didn't stop")
+	}
+
+	chk.mux.Lock()
+	up, down := chk.checks[nodes[0].URL.String()], chk.checks[nodes[1].URL.String()]
+	chk.mux.Unlock()
+	if up < 2 || up > 6 {
+		t.Errorf("alive node checked %d times in 4 periods, want about 4", up)
+	}
+	if down < 3*up {
+		t.Errorf("dead node checked %d times, want much more than the alive node (%d)", down, up)
+	}
+	if nodes[1].IsAlive() {
+		t.Errorf("dead node is alive after passive health check")
+	}
+}
+
+func TestRestartPassiveHealthCheck(t *testing.T) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	nodes, _ := node.CreateFakeNodes()
+	chk := &countingChecker{checks: map[string]int{}}
+	pool := NewServerPool(nodes[:1], chk)
+
+	pool.StartPassiveHealthCheck(time.Millisecond, nil, nil)
+	firstStop, firstDone := make(chan bool), make(chan bool)
+	pool.StartPassiveHealthCheck(time.Millisecond, firstStop, firstDone)
+	stop, done := make(chan bool), make(chan bool)
+	pool.StartPassiveHealthCheck(time.Millisecond, stop, done)
+	select {
+	case <-firstDone:
+	case <-time.After(time.Second):
+		t.Fatal("previous passive health check didn't stop when started again")
+	}
+
+	stop <- true
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("passive health check didn't stop")
+	}
+	chk.mux.Lock()
+	checks := chk.checks[nodes[0].URL.String()]
+	chk.mux.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	chk.mux.Lock()
+	defer chk.mux.Unlock()
+	if chk.checks[nodes[0].URL.String()] != checks {
+		t.Errorf("node is still checked after the passive health check started twice stopped")
+	}
+}
+
+func TestMaxConcurrentChecks(t *testing.T) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	var nodes []*node.Node
+	for i := 0; i < 10; i++ {
+		u, _ := url.Parse(fmt.Sprintf("http://localhost:%d", 9000+i))
+		nodes = append(nodes, node.New(u, true, nil, nil))
+	}
+	chk := &countingChecker{delay: 10 * time.Millisecond, checks: map[string]int{}}
+	pool := NewServerPool(nodes, chk)
+	pool.SetMaxConcurrentChecks(3)
+	pool.passiveHealthCheck(context.Background())
+	if chk.maxPeers != 3 || len(chk.checks) != len(nodes) {
+		t.Errorf("passiveHealthCheck() ran %d checks at the same time on %d nodes, want 3 on %d",
+			chk.maxPeers, len(chk.checks), len(nodes))
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		if d := jitter(time.Second, 0.1); d < 900*time.Millisecond || d > 1100*time.Millisecond {
+			t.Fatalf("jitter(1s, 0.1) = %s, want within 10%%", d)
+		}
+	}
+	if d := jitter(time.Second, 0); d != time.Second {
+		t.Errorf("jitter(1s, 0) = %s, want 1s", d)
+	}
+}
+
+func TestAddRemoveNode(t *testing.T) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	nodes, _ := node.CreateFakeNodes()
+	chk := &countingChecker{checks: map[string]int{}}
+	pool := NewServerPool(nodes[:1], chk)
+
+	stop, done := make(chan bool), make(chan bool)
+	pool.StartPassiveHealthCheck(10*time.Millisecond, stop, done)
+	if _, err := pool.AddNode(nodes[1]); err != nil {
+		t.Fatalf("ServerPool.AddNode(%s) = %s, want no error", nodes[1].URL, err)
+	}
+	if _, err := pool.AddNode(nodes[1]); !errors.Is(err, ErrNodeExists) {
+		t.Errorf("ServerPool.AddNode(%s) twice = %v, want %s", nodes[1].URL, err, ErrNodeExists)
+	}
+	time.Sleep(50 * time.Millisecond)
+	removed, list, err := pool.RemoveNode(nodes[0].URL.Host)
+	if err != nil || removed != nodes[0] || len(list) != 1 || list[0] != nodes[1] {
+		t.Fatalf("ServerPool.RemoveNode(%s) = %v, %v, %v, want the removed node", nodes[0].URL.Host, removed, list, err)
+	}
+	if _, _, err := pool.RemoveNode(nodes[0].URL.Host); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("ServerPool.RemoveNode(%s) twice = %v, want %s", nodes[0].URL.Host, err, ErrNodeNotFound)
+	}
+
+	chk.mux.Lock()
+	removedChecks := chk.checks[nodes[0].URL.String()]
+	chk.mux.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	stop <- true
+	<-done
+
+	chk.mux.Lock()
+	defer chk.mux.Unlock()
+	if chk.checks[nodes[1].URL.String()] == 0 {
+		t.Errorf("added node isn't checked")
+	}
+	if chk.checks[nodes[0].URL.String()] > removedChecks+1 {
+		t.Errorf("removed node is still checked")
+	}
+}
+
+// blockingChecker fails after ctx is canceled
+type blockingChecker struct {
+	started chan struct{}
+}
+
+func (c blockingChecker) Check(ctx context.Context, _ *url.URL) checker.Result {
+	select {
+	case c.started <- struct{}{}:
+	default:
+	}
+	<-ctx.Done()
+	return checker.Result{Healthy: false}
+}
+
+func TestStopCancelsChecks(t *testing.T) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	nodes, _ := node.CreateFakeNodes()
+	nodes = nodes[:1]
+	nodes[0].SetAlive(true)
+	chk := blockingChecker{started: make(chan struct{}, 1)}
+	pool := NewServerPool(nodes, chk)
+
+	stop, done := make(chan bool), make(chan bool)
+	pool.StartPassiveHealthCheck(time.Millisecond, stop, done)
+	<-chk.started
+	stop <- true
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("passive health check didn't stop while a check was running")
+	}
+	if !nodes[0].IsAlive() {
+		t.Errorf("result of a canceled check was applied")
+	}
+}
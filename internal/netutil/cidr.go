@@ -0,0 +1,71 @@
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// CIDRList is a list of networks, e.g. trusted sources
+type CIDRList []*net.IPNet
+
+// ParseCIDRList parses networks in CIDR notation. A bare IP is treated as a single host network.
+func ParseCIDRList(cidrs []string) (CIDRList, error) {
+	list := make(CIDRList, 0, len(cidrs))
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			ip := net.ParseIP(c)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP: %s", c)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			list = append(list, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR: %s", c)
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+// Contains reports whether ip belongs to any of the networks
+func (l CIDRList) Contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range l {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// HostIP extracts the IP of a "host:port" or bare host address. It returns nil if the host is not an IP.
+func HostIP(addr string) net.IP {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = strings.Trim(addr, "[]")
+	}
+	return net.ParseIP(host)
+}
+
+// AddrIP extracts the IP of a network address
+func AddrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	case nil:
+		return nil
+	default:
+		return HostIP(a.String())
+	}
+}
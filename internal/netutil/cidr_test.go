@@ -0,0 +1,51 @@
+package netutil
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseCIDRList(t *testing.T) {
+	list, err := ParseCIDRList([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	if err != nil {
+		t.Fatalf("ParseCIDRList() returns error: %s", err)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"192.168.1.1", true},
+		{"192.168.1.2", false},
+		{"fd00::1", true},
+		{"2001:db8::1", false},
+	}
+	for _, test := range tests {
+		if got := list.Contains(net.ParseIP(test.ip)); got != test.want {
+			t.Errorf("CIDRList.Contains(%s) = %t, want %t", test.ip, got, test.want)
+		}
+	}
+
+	if _, err := ParseCIDRList([]string{"10.0.0.0/33"}); err == nil {
+		t.Errorf("ParseCIDRList(invalid CIDR) doesn't return error")
+	}
+	if _, err := ParseCIDRList([]string{"localhost"}); err == nil {
+		t.Errorf("ParseCIDRList(invalid IP) doesn't return error")
+	}
+}
+
+func TestHostIP(t *testing.T) {
+	tests := map[string]string{
+		"1.2.3.4:80":  "1.2.3.4",
+		"1.2.3.4":     "1.2.3.4",
+		"[::1]:8000":  "::1",
+		"[::1]":       "::1",
+		"localhost:1": "<nil>",
+	}
+	for addr, want := range tests {
+		if got := HostIP(addr).String(); got != want {
+			t.Errorf("HostIP(%s) = %s, want %s", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	V1 = 1
+	V2 = 2
+
+	v1Prefix    = "PROXY "
+	v1MaxLength = 107 // including CRLF
+
+	v2HeaderLength = 16
+	v2CmdLocal     = 0x0
+	v2CmdProxy     = 0x1
+	v2FamInet      = 0x1
+	v2FamInet6     = 0x2
+	v2ProtoStream  = 0x1
+)
+
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ErrNoHeader is returned when a connection doesn't start with a PROXY protocol header
+var ErrNoHeader = errors.New("no PROXY protocol header")
+
+// Header is a PROXY protocol header. Nil addresses mean the connection addresses should be used
+// (v1 UNKNOWN or v2 LOCAL).
+type Header struct {
+	Version     int
+	Source      *net.TCPAddr
+	Destination *net.TCPAddr
+}
+
+// Read reads a v1 or v2 header from the reader. ErrNoHeader is returned (and nothing is consumed) if
+// the stream doesn't start with a header.
+func Read(br *bufio.Reader) (*Header, error) {
+	b, err := br.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	switch b[0] {
+	case v1Prefix[0]:
+		if b, err := br.Peek(len(v1Prefix)); err != nil || string(b) != v1Prefix {
+			return nil, ErrNoHeader
+		}
+		return readV1(br)
+	case v2Signature[0]:
+		if b, err := br.Peek(len(v2Signature)); err != nil || !bytes.Equal(b, v2Signature) {
+			return nil, ErrNoHeader
+		}
+		return readV2(br)
+	default:
+		return nil, ErrNoHeader
+	}
+}
+
+func readV1(br *bufio.Reader) (*Header, error) {
+	var line []byte
+	for len(line) < v1MaxLength {
+		c, err := br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, c)
+		if c == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("invalid PROXY v1 header: line too long or not terminated")
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	h := &Header{Version: V1}
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return h, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("invalid PROXY v1 header: %q", line)
+	}
+	src, err := parseV1Addr(fields[1], fields[2], fields[4])
+	if err != nil {
+		return nil, err
+	}
+	dst, err := parseV1Addr(fields[1], fields[3], fields[5])
+	if err != nil {
+		return nil, err
+	}
+	h.Source, h.Destination = src, dst
+	return h, nil
+}
+
+func parseV1Addr(proto, ip, port string) (*net.TCPAddr, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil || (proto == "TCP4") != (addr.To4() != nil) {
+		return nil, fmt.Errorf("invalid PROXY v1 address: %s", ip)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROXY v1 port: %s", port)
+	}
+	return &net.TCPAddr{IP: addr, Port: int(p)}, nil
+}
+
+func readV2(br *bufio.Reader) (*Header, error) {
+	var fixed [v2HeaderLength]byte
+	if _, err := io.ReadFull(br, fixed[:]); err != nil {
+		return nil, err
+	}
+	if fixed[12]>>4 != V2 {
+		return nil, fmt.Errorf("invalid PROXY v2 version: %d", fixed[12]>>4)
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(fixed[14:]))
+	if _, err := io.ReadFull(br, payload); err != nil {
+		return nil, err
+	}
+
+	h := &Header{Version: V2}
+	switch fixed[12] & 0x0F {
+	case v2CmdLocal:
+		return h, nil
+	case v2CmdProxy:
+	default:
+		return nil, fmt.Errorf("invalid PROXY v2 command: %d", fixed[12]&0x0F)
+	}
+
+	var ipLen int
+	switch fixed[13] >> 4 {
+	case v2FamInet:
+		ipLen = net.IPv4len
+	case v2FamInet6:
+		ipLen = net.IPv6len
+	default:
+		return h, nil // unsupported family (e.g. unix), use connection addresses
+	}
+	if len(payload) < 2*ipLen+4 {
+		return nil, fmt.Errorf("invalid PROXY v2 address length: %d", len(payload))
+	}
+	h.Source = &net.TCPAddr{
+		IP:   net.IP(payload[:ipLen]),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLen:])),
+	}
+	h.Destination = &net.TCPAddr{
+		IP:   net.IP(payload[ipLen : 2*ipLen]),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLen+2:])),
+	}
+	return h, nil
+}
+
+// WriteTo writes the header in its version's wire format
+func (h *Header) WriteTo(w io.Writer) (int64, error) {
+	var b []byte
+	switch h.Version {
+	case V1:
+		b = h.formatV1()
+	case V2:
+		b = h.formatV2()
+	default:
+		return 0, fmt.Errorf("invalid PROXY protocol version: %d", h.Version)
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+func (h *Header) formatV1() []byte {
+	if h.Source == nil || h.Destination == nil {
+		return []byte("PROXY UNKNOWN\r\n")
+	}
+	proto := "TCP6"
+	src, dst := h.Source.IP, h.Destination.IP
+	if src.To4() != nil && dst.To4() != nil {
+		proto = "TCP4"
+		src, dst = src.To4(), dst.To4()
+	}
+	return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", proto, src, dst, h.Source.Port, h.Destination.Port))
+}
+
+func (h *Header) formatV2() []byte {
+	b := append([]byte{}, v2Signature...)
+	if h.Source == nil || h.Destination == nil {
+		return append(b, V2<<4|v2CmdLocal, 0, 0, 0)
+	}
+
+	fam := byte(v2FamInet6)
+	src, dst := h.Source.IP.To16(), h.Destination.IP.To16()
+	if h.Source.IP.To4() != nil && h.Destination.IP.To4() != nil {
+		fam = v2FamInet
+		src, dst = h.Source.IP.To4(), h.Destination.IP.To4()
+	}
+	b = append(b, V2<<4|v2CmdProxy, fam<<4|v2ProtoStream)
+	b = binary.BigEndian.AppendUint16(b, uint16(2*len(src)+4))
+	b = append(b, src...)
+	b = append(b, dst...)
+	b = binary.BigEndian.AppendUint16(b, uint16(h.Source.Port))
+	b = binary.BigEndian.AppendUint16(b, uint16(h.Destination.Port))
+	return b
+}
@@ -0,0 +1,92 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		dst  string
+	}{
+		{"IPv4", "192.168.0.1:56324", "10.0.0.1:443"},
+		{"IPv6", "[2001:db8::1]:56324", "[2001:db8::2]:443"},
+	}
+	for _, version := range []int{V1, V2} {
+		for _, test := range tests {
+			t.Run(fmt.Sprintf("V%d%s", version, test.name), func(t *testing.T) {
+				src, _ := net.ResolveTCPAddr("tcp", test.src)
+				dst, _ := net.ResolveTCPAddr("tcp", test.dst)
+				h := &Header{Version: version, Source: src, Destination: dst}
+
+				var buf bytes.Buffer
+				if _, err := h.WriteTo(&buf); err != nil {
+					t.Fatalf("Header.WriteTo() returns error: %s", err)
+				}
+				buf.WriteString("GET / HTTP/1.1\r\n")
+
+				br := bufio.NewReader(&buf)
+				got, err := Read(br)
+				if err != nil {
+					t.Fatalf("Read() returns error: %s", err)
+				}
+				if got.Source.String() != test.src || got.Destination.String() != test.dst {
+					t.Errorf("Read() = %s -> %s, want %s -> %s", got.Source, got.Destination, test.src, test.dst)
+				}
+				if rest, _ := br.ReadString('\n'); rest != "GET / HTTP/1.1\r\n" {
+					t.Errorf("Read() consumed payload, remaining: %q", rest)
+				}
+			})
+		}
+	}
+}
+
+func TestReadLocal(t *testing.T) {
+	for _, version := range []int{V1, V2} {
+		var buf bytes.Buffer
+		if _, err := (&Header{Version: version}).WriteTo(&buf); err != nil {
+			t.Fatalf("Header.WriteTo() returns error: %s", err)
+		}
+		h, err := Read(bufio.NewReader(&buf))
+		if err != nil {
+			t.Fatalf("Read(v%d local header) returns error: %s", version, err)
+		}
+		if h.Source != nil || h.Destination != nil {
+			t.Errorf("Read(v%d local header) has addresses", version)
+		}
+	}
+}
+
+func TestReadNoHeader(t *testing.T) {
+	for _, payload := range []string{"GET / HTTP/1.1\r\n", "POST / HTTP/1.1\r\n", "PROXZ"} {
+		br := bufio.NewReader(strings.NewReader(payload))
+		if _, err := Read(br); !errors.Is(err, ErrNoHeader) {
+			t.Errorf("Read(%q) error = %v, want ErrNoHeader", payload, err)
+		}
+		if rest, _ := br.ReadString(0); rest != payload {
+			t.Errorf("Read(%q) consumed payload", payload)
+		}
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	payloads := []string{
+		"PROXY TCP4 1.2.3.4 5.6.7.8 80\r\n",
+		"PROXY TCP4 ::1 ::1 80 80\r\n",
+		"PROXY TCP4 1.2.3.4 5.6.7.8 80 99999\r\n",
+		"PROXY TCP4 1.2.3.4 5.6.7.8 80 80" + strings.Repeat(" ", 100) + "\r\n",
+		string(v2Signature) + "\x11\x11\x00\x0c",
+	}
+	for _, payload := range payloads {
+		if _, err := Read(bufio.NewReader(strings.NewReader(payload))); err == nil {
+			t.Errorf("Read(%q) doesn't return error", payload)
+		}
+	}
+}
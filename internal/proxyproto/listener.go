@@ -0,0 +1,102 @@
+package proxyproto
+
+import (
+	"bufio"
+	"errors"
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/internal/netutil"
+	"net"
+	"sync"
+	"time"
+)
+
+const defaultHeaderTimeout = 5 * time.Second
+
+// Listener accepts connections and reads PROXY protocol headers sent by trusted sources.
+// Connections from other sources are returned untouched.
+type Listener struct {
+	net.Listener
+	Trusted netutil.CIDRList
+	Timeout time.Duration // header read timeout
+}
+
+func NewListener(ln net.Listener, cfg *configs.Config) (*Listener, error) {
+	trusted, err := netutil.ParseCIDRList(cfg.ProxyProtocol.TrustedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	timeout := defaultHeaderTimeout
+	if cfg.ProxyProtocol.Timeout > 0 {
+		timeout = time.Millisecond * time.Duration(cfg.ProxyProtocol.Timeout)
+	}
+	return &Listener{
+		Listener: ln,
+		Trusted:  trusted,
+		Timeout:  timeout,
+	}, nil
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.Trusted.Contains(netutil.AddrIP(c.RemoteAddr())) {
+		return c, nil
+	}
+	return &Conn{
+		Conn:    c,
+		br:      bufio.NewReader(c),
+		timeout: l.Timeout,
+	}, nil
+}
+
+// Conn is a connection which may start with a PROXY protocol header. The header is read lazily on the first
+// Read, RemoteAddr or LocalAddr call so that Accept is never blocked by a slow client.
+type Conn struct {
+	net.Conn
+	br      *bufio.Reader
+	timeout time.Duration
+	once    sync.Once
+	header  *Header
+	err     error
+}
+
+func (c *Conn) readHeader() {
+	if c.timeout > 0 {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+		defer func() { _ = c.Conn.SetReadDeadline(time.Time{}) }()
+	}
+	h, err := Read(c.br)
+	if err != nil && !errors.Is(err, ErrNoHeader) {
+		c.err = err
+		return
+	}
+	c.header = h
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.br.Read(b)
+}
+
+// RemoteAddr returns the client address sent in the header, if any
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.header != nil && c.header.Source != nil {
+		return c.header.Source
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address sent in the header, if any
+func (c *Conn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.header != nil && c.header.Destination != nil {
+		return c.header.Destination
+	}
+	return c.Conn.LocalAddr()
+}
@@ -0,0 +1,115 @@
+package proxyproto
+
+import (
+	"context"
+	"fmt"
+	"github.com/samanazadi/load-balancer/configs"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func newListener(t *testing.T, trusted ...string) *Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &configs.Config{ProxyProtocol: configs.ProxyProtocol{Enabled: true, TrustedCIDRs: trusted}}
+	pln, err := NewListener(ln, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pln
+}
+
+func TestListenerTrusted(t *testing.T) {
+	ln := newListener(t, "127.0.0.0/8")
+	defer ln.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "PROXY TCP4 203.0.113.7 10.0.0.1 4000 80\r\nhello")
+	}()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if got := conn.RemoteAddr().String(); got != "203.0.113.7:4000" {
+		t.Errorf("Conn.RemoteAddr() = %s, want 203.0.113.7:4000", got)
+	}
+	if got := conn.LocalAddr().String(); got != "10.0.0.1:80" {
+		t.Errorf("Conn.LocalAddr() = %s, want 10.0.0.1:80", got)
+	}
+	b := make([]byte, 5)
+	if _, err := conn.Read(b); err != nil || string(b) != "hello" {
+		t.Errorf("Conn.Read() = %q (%v), want hello", b, err)
+	}
+}
+
+func TestListenerUntrusted(t *testing.T) {
+	ln := newListener(t, "10.0.0.0/8")
+	defer ln.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "PROXY TCP4 203.0.113.7 10.0.0.1 4000 80\r\n")
+	}()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, ok := conn.(*Conn); ok {
+		t.Errorf("Listener.Accept() parses headers of an untrusted source")
+	}
+	if got := conn.RemoteAddr().String(); got == "203.0.113.7:4000" {
+		t.Errorf("Conn.RemoteAddr() = %s from an untrusted source", got)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	// backend accepting PROXY protocol v2
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(rw, r.RemoteAddr)
+	}))
+	backend.Listener = newListener(t, "127.0.0.1")
+	backend.Start()
+	defer backend.Close()
+
+	rt, err := NewTransport(V2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(backend.URL)
+	r := httptest.NewRequest("GET", backend.URL, nil)
+	r.RequestURI, r.URL = "", u
+	r.RemoteAddr = "198.51.100.3:1234"
+	local := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 80}
+	res, err := rt.RoundTrip(r.WithContext(context.WithValue(r.Context(), http.LocalAddrContextKey, local)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body := make([]byte, 64)
+	n, _ := res.Body.Read(body)
+	if got := string(body[:n]); got != r.RemoteAddr {
+		t.Errorf("backend saw client %s, want %s", got, r.RemoteAddr)
+	}
+
+	if _, err := NewTransport(3); err == nil {
+		t.Errorf("NewTransport(3) doesn't return error")
+	}
+}
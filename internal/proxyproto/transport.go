@@ -0,0 +1,69 @@
+package proxyproto
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type sourceKey struct{}
+
+// transport sends a PROXY protocol header at the beginning of every backend connection
+type transport struct {
+	*http.Transport
+}
+
+// NewTransport creates a round tripper which announces the original client of each request to the backend using
+// the given PROXY protocol version. Keep-alive is disabled since a header describes a single client connection.
+func NewTransport(version int) (http.RoundTripper, error) {
+	if version != V1 && version != V2 {
+		return nil, fmt.Errorf("invalid PROXY protocol version: %d", version)
+	}
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DisableKeepAlives = true
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		h := &Header{Version: version}
+		src, _ := ctx.Value(sourceKey{}).(*net.TCPAddr)
+		dst, _ := ctx.Value(http.LocalAddrContextKey).(*net.TCPAddr)
+		if src != nil && dst != nil {
+			h.Source, h.Destination = src, dst
+		}
+		if _, err := h.WriteTo(conn); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+	return transport{Transport: t}, nil
+}
+
+func (t transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if src := parseTCPAddr(r.RemoteAddr); src != nil {
+		r = r.WithContext(context.WithValue(r.Context(), sourceKey{}, src))
+	}
+	return t.Transport.RoundTrip(r)
+}
+
+func parseTCPAddr(addr string) *net.TCPAddr {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	p, err := strconv.Atoi(port)
+	if ip == nil || err != nil {
+		return nil
+	}
+	return &net.TCPAddr{IP: ip, Port: p}
+}
@@ -0,0 +1,83 @@
+package rolling
+
+import (
+	"sync"
+	"time"
+)
+
+// Window sums counters over a sliding time window made of fixed-width buckets. Every bucket holds one value per
+// series, e.g. requests and retries.
+type Window struct {
+	mux     sync.Mutex
+	width   time.Duration
+	epochs  []int64   // epoch (time / width) of each bucket
+	buckets [][]int64 // values of each bucket, one per series
+	Now     func() time.Time
+}
+
+func NewWindow(series, size int, width time.Duration) *Window {
+	w := &Window{
+		width:   width,
+		epochs:  make([]int64, size),
+		buckets: make([][]int64, size),
+		Now:     time.Now,
+	}
+	for i := range w.buckets {
+		w.buckets[i] = make([]int64, series)
+		w.epochs[i] = -1
+	}
+	return w
+}
+
+// Length returns the duration covered by the window
+func (w *Window) Length() time.Duration {
+	return w.width * time.Duration(len(w.buckets))
+}
+
+func (w *Window) epoch() int64 {
+	return w.Now().UnixNano() / int64(w.width)
+}
+
+// Add adds n to a series in the current bucket
+func (w *Window) Add(series int, n int64) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	epoch := w.epoch()
+	i := int(epoch % int64(len(w.buckets)))
+	if w.epochs[i] != epoch { // stale bucket
+		w.epochs[i] = epoch
+		for j := range w.buckets[i] {
+			w.buckets[i][j] = 0
+		}
+	}
+	w.buckets[i][series] += n
+}
+
+// Sums returns the sum of each series over the window
+func (w *Window) Sums() []int64 {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	epoch := w.epoch()
+	sums := make([]int64, len(w.buckets[0]))
+	for i, values := range w.buckets {
+		if epoch-w.epochs[i] >= int64(len(w.buckets)) {
+			continue // stale bucket
+		}
+		for j, v := range values {
+			sums[j] += v
+		}
+	}
+	return sums
+}
+
+// Reset clears all buckets
+func (w *Window) Reset() {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	for i := range w.buckets {
+		w.epochs[i] = -1
+		for j := range w.buckets[i] {
+			w.buckets[i][j] = 0
+		}
+	}
+}
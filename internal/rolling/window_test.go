@@ -0,0 +1,47 @@
+package rolling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindow(t *testing.T) {
+	now := time.Unix(1000, 0)
+	w := NewWindow(2, 3, time.Second)
+	w.Now = func() time.Time { return now }
+
+	w.Add(0, 1)
+	w.Add(1, 10)
+	now = now.Add(time.Second)
+	w.Add(0, 2)
+	now = now.Add(time.Second)
+	w.Add(0, 4)
+	if sums := w.Sums(); sums[0] != 7 || sums[1] != 10 {
+		t.Errorf("Window.Sums() = %v, want [7 10]", sums)
+	}
+
+	// first bucket slides out
+	now = now.Add(time.Second)
+	if sums := w.Sums(); sums[0] != 6 || sums[1] != 0 {
+		t.Errorf("Window.Sums() = %v after 1 bucket, want [6 0]", sums)
+	}
+	w.Add(0, 8) // reuses the first bucket
+	if sums := w.Sums(); sums[0] != 14 {
+		t.Errorf("Window.Sums() = %v after reusing a bucket, want [14 0]", sums)
+	}
+
+	// everything slides out
+	now = now.Add(time.Hour)
+	if sums := w.Sums(); sums[0] != 0 || sums[1] != 0 {
+		t.Errorf("Window.Sums() = %v after an hour, want [0 0]", sums)
+	}
+
+	w.Add(1, 1)
+	w.Reset()
+	if sums := w.Sums(); sums[1] != 0 {
+		t.Errorf("Window.Sums() = %v after Reset(), want [0 0]", sums)
+	}
+	if got := w.Length(); got != 3*time.Second {
+		t.Errorf("Window.Length() = %s, want 3s", got)
+	}
+}
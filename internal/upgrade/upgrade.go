@@ -0,0 +1,148 @@
+package upgrade
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// environment variables of the new process, holding inherited file descriptors
+const (
+	ListenerEnv      = "LB_LISTENER_FD" // listening socket
+	ReadyEnv         = "LB_READY_FD"    // pipe written when the new process serves
+	AdminListenerEnv = "LB_ADMIN_FD"    // listening socket of the admin API, if enabled
+)
+
+// Listen returns the listener inherited from the old process during an upgrade, or a new listener on addr. New
+// listeners don't set SO_REUSEPORT, so traffic is never split with another process which binds addr; the new process
+// of an upgrade inherits the listener instead.
+func Listen(addr string) (net.Listener, error) {
+	return listen(ListenerEnv, "listener", addr)
+}
+
+// ListenAdmin is like Listen for the admin API. If addr is empty because the admin API is disabled, an inherited
+// listener is closed and nil is returned.
+func ListenAdmin(addr string) (net.Listener, error) {
+	if addr == "" {
+		f, err := inherited(AdminListenerEnv, "admin listener")
+		if f != nil {
+			f.Close()
+		}
+		return nil, err
+	}
+	return listen(AdminListenerEnv, "admin listener", addr)
+}
+
+func listen(env, name, addr string) (net.Listener, error) {
+	f, err := inherited(env, name)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		return net.Listen("tcp", addr)
+	}
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot use inherited %s: %s", name, err.Error())
+	}
+	return ln, nil
+}
+
+// Ready tells the old process that this process serves, so it can stop accepting and drain. It does nothing if this
+// process isn't started by an upgrade.
+func Ready() error {
+	f, err := inherited(ReadyEnv, "ready pipe")
+	if err != nil || f == nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write([]byte{1}); err != nil {
+		return fmt.Errorf("cannot notify old process: %s", err.Error())
+	}
+	return nil
+}
+
+// inherited returns the file whose descriptor is in the environment variable env, nil if it isn't set
+func inherited(env, name string) (*os.File, error) {
+	v := os.Getenv(env)
+	if v == "" {
+		return nil, nil
+	}
+	os.Unsetenv(env) // not passed to further upgrades
+	fd, err := strconv.Atoi(v)
+	if err != nil || fd < 3 {
+		return nil, fmt.Errorf("invalid inherited %s: %s=%s", name, env, v)
+	}
+	return os.NewFile(uintptr(fd), name), nil
+}
+
+// Start starts the current executable with the same arguments, passing it ln and adminLn, and waits up to timeout
+// until it calls Ready. The caller should then stop accepting on both and drain. ln and adminLn, which is nil if the
+// admin API is disabled, must be TCP listeners.
+func Start(ln, adminLn net.Listener, timeout time.Duration) (*os.Process, error) {
+	lf, err := file(ln, "listener")
+	if err != nil {
+		return nil, err
+	}
+	defer lf.Close()
+	files := []*os.File{lf, nil} // descriptors 3 and 4, the ready pipe
+	env := append(os.Environ(), ListenerEnv+"=3", ReadyEnv+"=4")
+	if adminLn != nil {
+		af, err := file(adminLn, "admin listener")
+		if err != nil {
+			return nil, err
+		}
+		defer af.Close()
+		files = append(files, af) // descriptor 5
+		env = append(env, AdminListenerEnv+"=5")
+	}
+	path, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("cannot find executable: %s", err.Error())
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	files[1] = w
+	cmd.ExtraFiles = files
+	cmd.Env = env
+	err = cmd.Start()
+	w.Close() // reading gets EOF if the new process exits before it is ready
+	if err != nil {
+		return nil, fmt.Errorf("cannot start new process: %s", err.Error())
+	}
+	go cmd.Wait() // reap the new process if this process outlives it
+
+	r.SetReadDeadline(time.Now().Add(timeout))
+	if _, err := r.Read(make([]byte, 1)); err != nil {
+		cmd.Process.Kill()
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, fmt.Errorf("new process isn't ready after %s", timeout)
+		}
+		return nil, errors.New("new process exited before it was ready")
+	}
+	return cmd.Process, nil
+}
+
+// file returns a duplicate of the descriptor of ln, which must be a TCP listener
+func file(ln net.Listener, name string) (*os.File, error) {
+	tl, ok := ln.(*net.TCPListener)
+	if !ok {
+		return nil, fmt.Errorf("cannot pass %s of type %T", name, ln)
+	}
+	f, err := tl.File()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get %s file: %s", name, err.Error())
+	}
+	return f, nil
+}
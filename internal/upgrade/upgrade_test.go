@@ -0,0 +1,100 @@
+package upgrade
+
+import (
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+const helperEnv = "LB_UPGRADE_TEST_HELPER"
+
+// TestMain runs the new process of an upgrade when the test binary is started by Start
+func TestMain(m *testing.M) {
+	switch os.Getenv(helperEnv) {
+	case "":
+		os.Exit(m.Run())
+	case "exit":
+		os.Exit(1) // fails before it is ready
+	}
+	ln, err := Listen("")
+	if err != nil {
+		os.Exit(2)
+	}
+	adminLn, err := ListenAdmin("127.0.0.1:0")
+	if err != nil {
+		os.Exit(2)
+	}
+	if err := Ready(); err != nil {
+		os.Exit(3)
+	}
+	for _, l := range []net.Listener{ln, adminLn} {
+		conn, err := l.Accept()
+		if err != nil {
+			os.Exit(4)
+		}
+		conn.Write([]byte("new " + l.Addr().String()))
+		conn.Close()
+	}
+	os.Exit(0)
+}
+
+func TestStart(t *testing.T) {
+	ln, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminLn, err := ListenAdmin("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(helperEnv, "serve")
+	if _, err := Start(ln, adminLn, 10*time.Second); err != nil {
+		t.Fatalf("Start() = %s, want no error", err)
+	}
+	ln.Close() // old process stops accepting
+	adminLn.Close()
+
+	for _, addr := range []string{ln.Addr().String(), adminLn.Addr().String()} {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("cannot connect to %s after upgrade: %s", addr, err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		if b, err := io.ReadAll(conn); err != nil || string(b) != "new "+addr {
+			t.Errorf("connection to %s after upgrade got %q %v, want it served by the new process", addr, b, err)
+		}
+		conn.Close()
+	}
+}
+
+func TestStartFailed(t *testing.T) {
+	ln, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	t.Setenv(helperEnv, "exit")
+	if _, err := Start(ln, nil, 10*time.Second); err == nil {
+		t.Errorf("Start() of a process exiting before it is ready = nil, want error")
+	}
+}
+
+func TestListenAdmin(t *testing.T) {
+	ln, err := ListenAdmin("")
+	if ln != nil || err != nil {
+		t.Errorf("ListenAdmin() of a disabled admin API = %v, %v, want nil", ln, err)
+	}
+
+	// the admin listener isn't shared either
+	ln, err = ListenAdmin("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if other, err := ListenAdmin(ln.Addr().String()); err == nil {
+		other.Close()
+		t.Errorf("ListenAdmin() on a port in use = nil, want error")
+	}
+}
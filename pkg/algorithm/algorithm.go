@@ -0,0 +1,58 @@
+package algorithm
+
+import (
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/models/node"
+	"github.com/samanazadi/load-balancer/pkg/registry"
+	"github.com/samanazadi/load-balancer/pkg/retry"
+	"net/http"
+)
+
+const (
+	RRType  = "rr"
+	CHType  = "ch"
+	WRRType = "wrr"
+	LCType  = "lc"
+)
+
+// Algorithm is a balancing algorithm like round-robin and consistent hashing
+type Algorithm interface {
+	GetNextEligibleNode(*http.Request) *node.Node // based on alive, argument and implementation logic (RR, ...)
+	SetNodes([]*node.Node)                        // safe under traffic, replaces the previous nodes
+}
+
+// available reports whether n can serve r: it is alive, enabled, not draining, not ejected, its circuit is ready and
+// r has not been sent to it by a previous attempt
+func Available(n *node.Node, r *http.Request) bool {
+	return !retry.Tried(r, n.URL) && inRotation(n) && n.Breaker.Ready()
+}
+
+// Eligible is like Available but also takes a probe slot of a half-open circuit breaker. It must be called only for
+// the node which is going to be returned.
+func Eligible(n *node.Node, r *http.Request) bool {
+	return !retry.Tried(r, n.URL) && inRotation(n) && n.Breaker.Allow()
+}
+
+func inRotation(n *node.Node) bool {
+	return n.IsAlive() && n.IsEnabled() && !n.IsDraining() && !n.IsEjected()
+}
+
+// algorithms are the registered algorithms by name
+var algorithms = registry.New[Algorithm, *configs.Config]("algorithm")
+
+// Register adds the algorithm name, created by newAlgorithm from its params decoded into P. P is a struct of params
+// with json tags, which is validated first if it implements registry.Validator. Built-in algorithms register
+// themselves this way, others are registered by init functions before New is called.
+func Register[P any](name string, newAlgorithm func(params P, cfg *configs.Config) (Algorithm, error)) {
+	registry.Register(algorithms, name, newAlgorithm)
+}
+
+// Names returns the names of the registered algorithms
+func Names() []string {
+	return algorithms.Names()
+}
+
+// New creates the algorithm of the config by its name and params
+func New(cfg *configs.Config) (Algorithm, error) {
+	return algorithms.New(cfg.Algorithm.Name, cfg.Algorithm.Params, cfg)
+}
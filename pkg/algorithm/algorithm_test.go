@@ -0,0 +1,117 @@
+package algorithm
+
+import (
+	"fmt"
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/logging"
+	"github.com/samanazadi/load-balancer/pkg/models/node"
+	"hash/crc32"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	// RR
+	cfg := &configs.Config{Algorithm: configs.Algorithm{Name: RRType}}
+	alg, err := New(cfg)
+	if _, ok := alg.(*RoundRobin); !ok {
+		t.Errorf("algoritm.New(RRType) != RoundRobin")
+	}
+	if err != nil {
+		t.Errorf("algoritm.New(TCPType) returns error")
+	}
+	// WRR
+	cfg = &configs.Config{Algorithm: configs.Algorithm{Name: WRRType}}
+	alg, err = New(cfg)
+	if _, ok := alg.(*WeightedRoundRobin); !ok || err != nil {
+		t.Errorf("algoritm.New(WRRType) != WeightedRoundRobin")
+	}
+	// LC
+	cfg = &configs.Config{Algorithm: configs.Algorithm{Name: LCType}}
+	alg, err = New(cfg)
+	if _, ok := alg.(*LeastConnections); !ok || err != nil {
+		t.Errorf("algoritm.New(LCType) != LeastConnections")
+	}
+	// invalid type
+	cfg = &configs.Config{Algorithm: configs.Algorithm{Name: "invalid"}}
+	alg, err = New(cfg)
+	if err == nil {
+		t.Errorf("algoritm.New(invalid type) doesn't return error")
+	}
+}
+
+func TestSetNodesUnderTraffic(t *testing.T) {
+	var nodes []*node.Node
+	for i := 0; i < 4; i++ {
+		u, _ := url.Parse(fmt.Sprintf("http://localhost:800%d", i))
+		nodes = append(nodes, node.New(u, true, nil, nil))
+	}
+	ch := &ConsistentHashing{Replicas: 10, HashFunc: crc32.ChecksumIEEE}
+	algorithms := map[string]Algorithm{
+		RRType: NewRoundRobin(), CHType: ch, WRRType: NewWeightedRoundRobin(), LCType: NewLeastConnections(),
+	}
+	for name, alg := range algorithms {
+		t.Run(name, func(t *testing.T) {
+			alg.SetNodes(nodes)
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					r := httptest.NewRequest(http.MethodGet, "/", nil)
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+							alg.GetNextEligibleNode(r)
+						}
+					}
+				}()
+			}
+			for i := 0; i < 100; i++ {
+				alg.SetNodes(nodes[:i%len(nodes)])
+			}
+			close(stop)
+			wg.Wait()
+
+			alg.SetNodes(nodes)
+			if n := alg.GetNextEligibleNode(httptest.NewRequest(http.MethodGet, "/", nil)); n == nil {
+				t.Errorf("%s.GetNextEligibleNode() = nil after SetNodes(%d nodes)", name, len(nodes))
+			}
+		})
+	}
+}
+
+func TestDrainingNode(t *testing.T) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	var nodes []*node.Node
+	for i := 0; i < 3; i++ {
+		u, _ := url.Parse(fmt.Sprintf("http://localhost:800%d", i))
+		nodes = append(nodes, node.New(u, true, nil, nil))
+	}
+	ch := &ConsistentHashing{Replicas: 10, HashFunc: crc32.ChecksumIEEE}
+	algorithms := map[string]Algorithm{
+		RRType: NewRoundRobin(), CHType: ch, WRRType: NewWeightedRoundRobin(), LCType: NewLeastConnections(),
+	}
+	for name, alg := range algorithms {
+		t.Run(name, func(t *testing.T) {
+			alg.SetNodes(nodes)
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			sticky := alg.GetNextEligibleNode(r)
+			sticky.Drain()
+			defer sticky.Undrain()
+			for i := 0; i < 10; i++ {
+				if n := alg.GetNextEligibleNode(r); n == nil || n == sticky {
+					t.Fatalf("%s.GetNextEligibleNode() = %v, want a node other than the draining %s", name, n, sticky.URL)
+				}
+			}
+		})
+	}
+}
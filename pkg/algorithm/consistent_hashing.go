@@ -3,27 +3,40 @@ package algorithm
 import (
 	"fmt"
 	"github.com/samanazadi/load-balancer/configs"
-	"github.com/samanazadi/load-balancer/internal/models/node"
+	"github.com/samanazadi/load-balancer/pkg/clientip"
+	"github.com/samanazadi/load-balancer/pkg/models/node"
 	"hash/crc32"
 	"net/http"
 	"sort"
 	"strconv"
+	"sync"
 )
 
 const CRC32Type = "crc32"
 
+func init() {
+	Register(CHType, newConsistentHashing)
+}
+
 type HashFunc func([]byte) uint32
 
 type ConsistentHashing struct {
+	mux         sync.RWMutex       // for protecting VNodes, ActualNodes and Nodes
 	Replicas    int                // replicas count
 	HashFunc    HashFunc           // hash function
 	VNodes      []int              // sorted virtual nodes
 	ActualNodes map[int]*node.Node // vnode to node
 	Nodes       []*node.Node       // original nodes
+	Resolver    *clientip.Resolver // real client IP of requests
 }
 
 func (ch *ConsistentHashing) GetNextEligibleNode(r *http.Request) *node.Node {
-	requestHash := int(ch.HashFunc([]byte(r.RemoteAddr)))
+	ch.mux.RLock()
+	defer ch.mux.RUnlock()
+	if len(ch.VNodes) == 0 {
+		return nil // no node
+	}
+	requestHash := int(ch.HashFunc([]byte(ch.Resolver.ClientIP(r))))
 	index := sort.Search(len(ch.VNodes), func(i int) bool { return ch.VNodes[i] >= requestHash }) // binary search
 
 	if index == len(ch.VNodes) { // spun a complete round
@@ -33,7 +46,7 @@ func (ch *ConsistentHashing) GetNextEligibleNode(r *http.Request) *node.Node {
 	// check for dead node
 	an := ch.ActualNodes[ch.VNodes[index]]
 	on, i := ch.getOriginalNode(an)
-	if on.IsAlive() {
+	if Eligible(on, r) {
 		return on
 	}
 	// get next node
@@ -41,7 +54,7 @@ func (ch *ConsistentHashing) GetNextEligibleNode(r *http.Request) *node.Node {
 	last := next + len(ch.Nodes)
 	for i := next; i < last; i++ {
 		index := i % len(ch.Nodes)
-		if ch.Nodes[index].IsAlive() {
+		if Eligible(ch.Nodes[index], r) {
 			return ch.Nodes[index]
 		}
 	}
@@ -57,9 +70,13 @@ func (ch *ConsistentHashing) getOriginalNode(n *node.Node) (*node.Node, int) {
 	return nil, 0
 }
 
+// SetNodes rebuilds the ring from nodes
 func (ch *ConsistentHashing) SetNodes(nodes []*node.Node) {
+	ch.mux.Lock()
+	defer ch.mux.Unlock()
 	ch.Nodes = nodes
 	// vnodes and actual nodes
+	ch.VNodes = make([]int, 0, len(nodes)*ch.Replicas)
 	ch.ActualNodes = make(map[int]*node.Node)
 	for _, n := range nodes {
 		for i := 0; i < ch.Replicas; i++ {
@@ -71,42 +88,35 @@ func (ch *ConsistentHashing) SetNodes(nodes []*node.Node) {
 	sort.Ints(ch.VNodes)
 }
 
-func ConsistentHashingParamDecode(m map[string]any) (replicas int, hashFunc string, err error) {
-	freplicas, ok := m["replicas"].(float64)
-	if !ok {
-		return 0, "", fmt.Errorf("consistent hasing invalid replicas ")
-	}
-	replicas = int(freplicas)
-
-	hashFunc, ok = m["hashFunc"].(string)
-	if !ok || hashFunc == "" {
-		return 0, "", fmt.Errorf("consistent hasing invalid hashFunc ")
-	}
-	return
+// ConsistentHashingParams are the params of consistent hashing
+type ConsistentHashingParams struct {
+	Replicas int    `json:"replicas"` // virtual nodes of every node
+	HashFunc string `json:"hashFunc"` // one of hashFuncs
 }
 
-func NewConsistentHashing(cfg *configs.Config) (Algorithm, error) {
-	replicas, hashFunc, err := ConsistentHashingParamDecode(cfg.Algorithm.Params)
-	if err != nil {
-		return nil, err
+func (p ConsistentHashingParams) Validate() error {
+	if p.Replicas < 1 {
+		return fmt.Errorf("invalid replicas: %d", p.Replicas)
 	}
-
-	// replicas
-	if replicas < 1 {
-		return nil, fmt.Errorf("invalid replicas: %d", replicas)
+	if _, ok := hashFuncs[p.HashFunc]; !ok {
+		return fmt.Errorf("invalid hashFunc: %q", p.HashFunc)
 	}
+	return nil
+}
 
-	// hash function
-	var hf HashFunc
-	switch hashFunc {
-	case CRC32Type:
-		hf = crc32.ChecksumIEEE
+// hashFuncs are the hash functions by name
+var hashFuncs = map[string]HashFunc{
+	CRC32Type: crc32.ChecksumIEEE,
+}
 
-	default:
-		return nil, fmt.Errorf("invalid hashFunc: %s", hashFunc)
+func newConsistentHashing(params ConsistentHashingParams, cfg *configs.Config) (Algorithm, error) {
+	resolver, err := clientip.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trustedProxies: %s", err.Error())
 	}
 	return &ConsistentHashing{
-		Replicas: replicas,
-		HashFunc: hf,
+		Replicas: params.Replicas,
+		HashFunc: hashFuncs[params.HashFunc],
+		Resolver: resolver,
 	}, nil
 }
@@ -3,8 +3,9 @@ package algorithm
 import (
 	"fmt"
 	"github.com/samanazadi/load-balancer/configs"
-	"github.com/samanazadi/load-balancer/internal/models/node"
+	"github.com/samanazadi/load-balancer/pkg/models/node"
 	"hash/crc32"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strconv"
@@ -17,6 +18,7 @@ func BenchmarkCHGetNextEligibleNode(b *testing.B) {
 	nodes := make([]*node.Node, 0, count)
 	cfg := &configs.Config{}
 	cfg.Port = 8000
+	cfg.Algorithm.Name = CHType
 	cfg.Algorithm.Params = map[string]any{"replicas": 100.0, "hashFunc": "crc32"}
 
 	for i := 0; i < count; i++ {
@@ -28,7 +30,7 @@ func BenchmarkCHGetNextEligibleNode(b *testing.B) {
 		nodes = append(nodes, n)
 	}
 
-	ch, _ := NewConsistentHashing(cfg)
+	ch, _ := New(cfg)
 	ch.SetNodes(nodes)
 
 	r := httptest.NewRequest("GET", "localhost:"+strconv.Itoa(cfg.Port), nil)
@@ -84,6 +86,50 @@ func TestCHSetNodes(t *testing.T) {
 			if len(ch.ActualNodes) != len(urls)*(replicas) {
 				t.Errorf("ConsistentHashing.SetNodes(%d nodes) caused %d actual nodes", len(urls), len(ch.ActualNodes))
 			}
+
+			// the ring is rebuilt on every call
+			ch.SetNodes(nodes)
+			ch.SetNodes(nodes[:2])
+			if len(ch.VNodes) != 2*replicas || len(ch.ActualNodes) != 2*replicas {
+				t.Errorf("ConsistentHashing.SetNodes(3 nodes, 3 nodes, 2 nodes) caused %d vnodes and %d actual nodes",
+					len(ch.VNodes), len(ch.ActualNodes))
+			}
 		})
 	}
 }
+
+func TestCHGetNextEligibleNodeClientIP(t *testing.T) {
+	var nodes []*node.Node
+	for i := 0; i < 10; i++ {
+		u, _ := url.Parse("http://localhost:" + strconv.Itoa(8000+i))
+		n := &node.Node{URL: u}
+		n.SetAlive(true)
+		nodes = append(nodes, n)
+	}
+	cfg := &configs.Config{TrustedProxies: []string{"10.0.0.1"}}
+	cfg.Algorithm.Name = CHType
+	cfg.Algorithm.Params = map[string]any{"replicas": 10.0, "hashFunc": "crc32"}
+	ch, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch.SetNodes(nodes)
+
+	// same client, different connections
+	r1 := httptest.NewRequest("GET", "/", nil)
+	r1.RemoteAddr = "203.0.113.5:1000"
+	r2 := httptest.NewRequest("GET", "/", nil)
+	r2.RemoteAddr = "203.0.113.5:2000"
+	// same client behind the trusted proxy
+	r3 := httptest.NewRequest("GET", "/", nil)
+	r3.RemoteAddr = "10.0.0.1:3000"
+	r3.Header.Set("X-Forwarded-For", "203.0.113.5")
+
+	want := ch.GetNextEligibleNode(r1).URL.String()
+	for _, r := range []*http.Request{r2, r3} {
+		if got := ch.GetNextEligibleNode(r).URL.String(); got != want {
+			t.Errorf("ConsistentHashing.GetNextEligibleNode(%s, XFF=%q) = %s, want %s",
+				r.RemoteAddr, r.Header.Get("X-Forwarded-For"), got, want)
+		}
+	}
+}
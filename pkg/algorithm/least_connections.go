@@ -0,0 +1,66 @@
+package algorithm
+
+import (
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/models/node"
+	"github.com/samanazadi/load-balancer/pkg/registry"
+	"net/http"
+	"sync"
+)
+
+func init() {
+	Register(LCType, func(registry.NoParams, *configs.Config) (Algorithm, error) {
+		return NewLeastConnections(), nil
+	})
+}
+
+// LeastConnections picks the node with the fewest in-flight requests relative to its effective weight
+type LeastConnections struct {
+	mux   sync.Mutex // for protecting start
+	start int        // first node to consider, rotated to break ties
+	Nodes []*node.Node
+}
+
+func (lc *LeastConnections) GetNextEligibleNode(r *http.Request) *node.Node {
+	lc.mux.Lock()
+	defer lc.mux.Unlock()
+	if len(lc.Nodes) == 0 {
+		return nil
+	}
+	lc.start = (lc.start + 1) % len(lc.Nodes)
+
+	excluded := make([]bool, len(lc.Nodes))
+	for range lc.Nodes {
+		best := -1
+		var bestScore float64
+		for i := lc.start; i < lc.start+len(lc.Nodes); i++ {
+			index := i % len(lc.Nodes)
+			n := lc.Nodes[index]
+			if excluded[index] || !Available(n, r) {
+				continue
+			}
+			score := float64(n.Active()+1) / n.EffectiveWeight()
+			if best == -1 || score < bestScore {
+				best, bestScore = index, score
+			}
+		}
+		if best == -1 {
+			return nil // no available node
+		}
+		if lc.Nodes[best].Breaker.Allow() {
+			return lc.Nodes[best]
+		}
+		excluded[best] = true // circuit changed meanwhile
+	}
+	return nil
+}
+
+func (lc *LeastConnections) SetNodes(nodes []*node.Node) {
+	lc.mux.Lock()
+	defer lc.mux.Unlock()
+	lc.Nodes = nodes
+}
+
+func NewLeastConnections() Algorithm {
+	return &LeastConnections{}
+}
@@ -0,0 +1,79 @@
+package algorithm
+
+import (
+	"github.com/samanazadi/load-balancer/pkg/models/node"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestLCGetNextEligibleNode(t *testing.T) {
+	// node 0 is busy with an in-flight request
+	release := make(chan bool)
+	backend := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { <-release }))
+	defer backend.Close()
+	u, _ := url.Parse(backend.URL)
+	busy := node.New(u, true, nil, nil)
+	done := make(chan bool)
+	go func() {
+		busy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		done <- true
+	}()
+	for busy.Active() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	nodes := append([]*node.Node{busy}, createWeightedNodes(1)...)
+	lc := NewLeastConnections()
+	lc.SetNodes(nodes)
+	for i := 0; i < 3; i++ {
+		if got := lc.GetNextEligibleNode(nil); got != nodes[1] {
+			t.Errorf("LeastConnections.GetNextEligibleNode() = %s, want the idle node %s", got.URL, nodes[1].URL)
+		}
+	}
+
+	close(release)
+	<-done
+	if busy.Active() != 0 {
+		t.Errorf("Node.Active() = %d after the request is done", busy.Active())
+	}
+}
+
+func TestLCWeights(t *testing.T) {
+	nodes := createWeightedNodes(1, 4, 2)
+	lc := NewLeastConnections()
+	lc.SetNodes(nodes)
+	for i := 0; i < 3; i++ {
+		if got := lc.GetNextEligibleNode(nil); got != nodes[1] {
+			t.Errorf("LeastConnections.GetNextEligibleNode() = %s, want the heaviest node", got.URL)
+		}
+	}
+
+	nodes[1].SetAlive(false)
+	if got := lc.GetNextEligibleNode(nil); got != nodes[2] {
+		t.Errorf("LeastConnections.GetNextEligibleNode() = %s, want %s", got.URL, nodes[2].URL)
+	}
+}
+
+func BenchmarkLCGetNextEligibleNode(b *testing.B) {
+	// setup
+	const count = 100
+	weights := make([]int, count)
+	for i := range weights {
+		weights[i] = i%5 + 1
+	}
+	nodes := createWeightedNodes(weights...)
+	for i, n := range nodes {
+		n.SetAlive(i%2 == 0)
+	}
+
+	lc := NewLeastConnections()
+	lc.SetNodes(nodes)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lc.GetNextEligibleNode(nil)
+	}
+}
@@ -0,0 +1,92 @@
+package algorithm_test
+
+import (
+	"fmt"
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/algorithm"
+	"github.com/samanazadi/load-balancer/pkg/models/node"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// first is an algorithm which always picks the first eligible node, registered like a module embedding the load
+// balancer
+type first struct {
+	mu    sync.RWMutex
+	nodes []*node.Node
+	skip  int
+}
+
+func (f *first) GetNextEligibleNode(r *http.Request) *node.Node {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if len(f.nodes) <= f.skip {
+		return nil
+	}
+	for _, n := range f.nodes[f.skip:] {
+		if algorithm.Eligible(n, r) {
+			return n
+		}
+	}
+	return nil
+}
+
+func (f *first) SetNodes(nodes []*node.Node) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nodes = nodes
+}
+
+type firstParams struct {
+	Skip int `json:"skip"`
+}
+
+func (p firstParams) Validate() error {
+	if p.Skip < 0 {
+		return fmt.Errorf("invalid skip: %d", p.Skip)
+	}
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	algorithm.Register("first", func(params firstParams, cfg *configs.Config) (algorithm.Algorithm, error) {
+		return &first{skip: params.Skip}, nil
+	})
+	if !slices.Contains(algorithm.Names(), "first") || !slices.Contains(algorithm.Names(), algorithm.CHType) {
+		t.Errorf("algorithm.Names() = %v, want built-in and registered algorithms", algorithm.Names())
+	}
+
+	cfg := &configs.Config{Algorithm: configs.Algorithm{Name: "first", Params: map[string]any{"skip": 1.0}}}
+	alg, err := algorithm.New(cfg)
+	if f, ok := alg.(*first); !ok || err != nil || f.skip != 1 {
+		t.Fatalf("algorithm.New(first) = %+v %v, want first with skip 1", alg, err)
+	}
+
+	nodes, _ := node.CreateFakeNodes()
+	alg.SetNodes(nodes)
+	nodes[1].SetAlive(false)
+	nodes[2].SetAlive(true)
+	if n := alg.GetNextEligibleNode(nil); n != nodes[2] {
+		t.Errorf("first.GetNextEligibleNode() = %v, want the first eligible node after skip %v", n, nodes[2])
+	}
+
+	for _, params := range []map[string]any{{"skip": -1.0}, {"skip": "1"}, {"skp": 1.0}} {
+		cfg.Algorithm.Params = params
+		if _, err := algorithm.New(cfg); err == nil {
+			t.Errorf("algorithm.New(first, %v) doesn't return error", params)
+		}
+	}
+
+	// built-in params are typed too
+	cfg = &configs.Config{Algorithm: configs.Algorithm{Name: algorithm.CHType, Params: map[string]any{"replicas": 0.0,
+		"hashFunc": "crc32"}}}
+	if _, err := algorithm.New(cfg); err == nil {
+		t.Errorf("algorithm.New(ch, 0 replicas) doesn't return error")
+	}
+	cfg = &configs.Config{Algorithm: configs.Algorithm{Name: algorithm.RRType, Params: map[string]any{"replicas": 2.0}}}
+	if _, err := algorithm.New(cfg); err == nil {
+		t.Errorf("algorithm.New(rr, unknown params) doesn't return error")
+	}
+}
@@ -0,0 +1,63 @@
+package algorithm
+
+import (
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/models/node"
+	"github.com/samanazadi/load-balancer/pkg/registry"
+	"net/http"
+	"sync"
+)
+
+func init() {
+	Register(RRType, func(registry.NoParams, *configs.Config) (Algorithm, error) {
+		return NewRoundRobin(), nil
+	})
+}
+
+type RoundRobin struct {
+	lastUsedIndex int
+	mux           sync.RWMutex // for protecting lastUsedIndex and Nodes from multiple access
+	Nodes         []*node.Node
+}
+
+// nextIndex returns the next index along the nodes it applies to
+func (rr *RoundRobin) nextIndex() (int, []*node.Node) {
+	rr.mux.Lock()
+	defer rr.mux.Unlock()
+	if len(rr.Nodes) == 0 {
+		return 0, nil
+	}
+	rr.lastUsedIndex++
+	rr.lastUsedIndex = rr.lastUsedIndex % len(rr.Nodes)
+	return rr.lastUsedIndex, rr.Nodes
+}
+
+func (rr *RoundRobin) GetNextEligibleNode(r *http.Request) *node.Node {
+	next, nodes := rr.nextIndex()
+	last := next + len(nodes)
+	for i := next; i < last; i++ {
+		index := i % len(nodes)
+		if Eligible(nodes[index], r) {
+			if i != next {
+				// store new current index (some unavailable nodes found)
+				rr.mux.Lock()
+				rr.lastUsedIndex = index
+				rr.mux.Unlock()
+			}
+			return nodes[index]
+		}
+	}
+	return nil // no available node
+}
+
+func (rr *RoundRobin) SetNodes(nodes []*node.Node) {
+	rr.mux.Lock()
+	defer rr.mux.Unlock()
+	rr.Nodes = nodes
+}
+
+func NewRoundRobin() Algorithm {
+	return &RoundRobin{
+		lastUsedIndex: -1,
+	}
+}
@@ -1,7 +1,7 @@
 package algorithm
 
 import (
-	"github.com/samanazadi/load-balancer/internal/models/node"
+	"github.com/samanazadi/load-balancer/pkg/models/node"
 	"net/url"
 	"testing"
 )
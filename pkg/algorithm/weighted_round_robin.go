@@ -0,0 +1,75 @@
+package algorithm
+
+import (
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/models/node"
+	"github.com/samanazadi/load-balancer/pkg/registry"
+	"net/http"
+	"sync"
+)
+
+func init() {
+	Register(WRRType, func(registry.NoParams, *configs.Config) (Algorithm, error) {
+		return NewWeightedRoundRobin(), nil
+	})
+}
+
+// WeightedRoundRobin is smooth weighted round-robin: nodes are picked in proportion to their effective weight,
+// interleaved rather than in bursts
+type WeightedRoundRobin struct {
+	mux     sync.Mutex // for protecting current
+	current []float64  // current weight of each node
+	Nodes   []*node.Node
+}
+
+func (wrr *WeightedRoundRobin) GetNextEligibleNode(r *http.Request) *node.Node {
+	wrr.mux.Lock()
+	defer wrr.mux.Unlock()
+
+	excluded := make([]bool, len(wrr.Nodes))
+	for range wrr.Nodes {
+		best := -1
+		total := 0.0
+		for i, n := range wrr.Nodes {
+			if excluded[i] || !Available(n, r) {
+				continue
+			}
+			w := n.EffectiveWeight()
+			wrr.current[i] += w
+			total += w
+			if best == -1 || wrr.current[i] > wrr.current[best] {
+				best = i
+			}
+		}
+		if best == -1 {
+			return nil // no available node
+		}
+		wrr.current[best] -= total
+		if wrr.Nodes[best].Breaker.Allow() {
+			return wrr.Nodes[best]
+		}
+		excluded[best] = true // circuit changed meanwhile
+	}
+	return nil
+}
+
+// SetNodes replaces the nodes, keeping the current weight of nodes which remain
+func (wrr *WeightedRoundRobin) SetNodes(nodes []*node.Node) {
+	wrr.mux.Lock()
+	defer wrr.mux.Unlock()
+	current := make([]float64, len(nodes))
+	for i, n := range nodes {
+		for j, old := range wrr.Nodes {
+			if old == n {
+				current[i] = wrr.current[j]
+				break
+			}
+		}
+	}
+	wrr.Nodes = nodes
+	wrr.current = current
+}
+
+func NewWeightedRoundRobin() Algorithm {
+	return &WeightedRoundRobin{}
+}
@@ -0,0 +1,85 @@
+package algorithm
+
+import (
+	"github.com/samanazadi/load-balancer/pkg/models/node"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func createWeightedNodes(weights ...int) []*node.Node {
+	var nodes []*node.Node
+	for i, w := range weights {
+		u, _ := url.Parse("http://localhost:" + strconv.Itoa(8001+i))
+		n := &node.Node{URL: u}
+		n.SetAlive(true)
+		n.SetWeight(w)
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+func TestWRRGetNextEligibleNode(t *testing.T) {
+	nodes := createWeightedNodes(5, 1, 1, 3)
+	nodes[3].SetAlive(false)
+	wrr := NewWeightedRoundRobin()
+	wrr.SetNodes(nodes)
+
+	// smooth: the heavy node is interleaved with the others
+	wants := []string{"8001", "8001", "8002", "8001", "8003", "8001", "8001"}
+	for i, want := range wants {
+		if got := wrr.GetNextEligibleNode(nil).URL.Port(); got != want {
+			t.Errorf("WeightedRoundRobin.GetNextEligibleNode() #%d = %s, want %s", i+1, got, want)
+		}
+	}
+}
+
+func TestWRRSlowStart(t *testing.T) {
+	nodes := createWeightedNodes(1, 1)
+	nodes[1].SlowStart = &node.SlowStart{Window: time.Hour, MinWeight: 0.1}
+	nodes[1].SetAlive(false)
+	nodes[1].SetAlive(true) // recovering
+	wrr := NewWeightedRoundRobin()
+	wrr.SetNodes(nodes)
+
+	counts := map[string]int{}
+	for i := 0; i < 110; i++ {
+		counts[wrr.GetNextEligibleNode(nil).URL.Port()]++
+	}
+	if counts["8002"] < 9 || counts["8002"] > 11 {
+		t.Errorf("recovering node got %d of 110 requests, want 10", counts["8002"])
+	}
+}
+
+func TestWRRNoNode(t *testing.T) {
+	nodes := createWeightedNodes(1, 1)
+	nodes[0].SetAlive(false)
+	nodes[1].SetAlive(false)
+	wrr := NewWeightedRoundRobin()
+	wrr.SetNodes(nodes)
+	if n := wrr.GetNextEligibleNode(nil); n != nil {
+		t.Errorf("WeightedRoundRobin.GetNextEligibleNode() = %s, want nil", n.URL)
+	}
+}
+
+func BenchmarkWRRGetNextEligibleNode(b *testing.B) {
+	// setup
+	const count = 100
+	weights := make([]int, count)
+	for i := range weights {
+		weights[i] = i%5 + 1
+	}
+	nodes := createWeightedNodes(weights...)
+	for i, n := range nodes {
+		n.SetAlive(i%2 == 0)
+	}
+
+	wrr := NewWeightedRoundRobin()
+	wrr.SetNodes(nodes)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		wrr.GetNextEligibleNode(nil)
+	}
+}
@@ -0,0 +1,214 @@
+package breaker
+
+import (
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/internal/rolling"
+	"sync"
+	"time"
+)
+
+// window series
+const (
+	total = iota
+	failed
+)
+
+type State int
+
+const (
+	Closed   State = iota // requests flow
+	Open                  // requests are rejected
+	HalfOpen              // a limited number of probe requests flow
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	default:
+		return "half-open"
+	}
+}
+
+// Breaker is a circuit breaker. It opens after consecutive failures or a high error rate, lets a few probe requests
+// through after a while (half-open) and closes when they succeed. A nil Breaker is always closed.
+type Breaker struct {
+	mux               sync.Mutex
+	state             State
+	consecutive       int       // consecutive failures while closed
+	openedAt          time.Time // last transition to open
+	probes, successes int       // in-flight and succeeded probes while half-open
+	window            *rolling.Window
+	MaxFailures       int     // consecutive failures which open the circuit, 0 to disable
+	ErrorRate         float64 // failure ratio which opens the circuit, 0 to disable
+	MinRequests       int64   // min requests in window for error rate to be considered
+	OpenDuration      time.Duration
+	HalfOpenRequests  int
+	OnStateChange     func(from, to State)
+	Now               func() time.Time
+}
+
+// New returns nil if neither consecutive failures nor error rate is configured
+func New(cfg *configs.Config) *Breaker {
+	ac := cfg.HealthCheck.Active
+	if ac.MaxRetry <= 0 && ac.ErrorRate <= 0 {
+		return nil
+	}
+	b := &Breaker{
+		MaxFailures:      ac.MaxRetry,
+		ErrorRate:        ac.ErrorRate,
+		MinRequests:      int64(ac.MinRequests),
+		OpenDuration:     time.Millisecond * time.Duration(ac.OpenDuration),
+		HalfOpenRequests: ac.HalfOpenRequests,
+		Now:              time.Now,
+	}
+	window := ac.Window
+	if window <= 0 {
+		window = configs.DefaultBreakerWindow
+	}
+	b.window = rolling.NewWindow(2, window, time.Second)
+	if b.MinRequests <= 0 {
+		b.MinRequests = configs.DefaultBreakerMinRequests
+	}
+	if b.OpenDuration <= 0 {
+		b.OpenDuration = time.Millisecond * configs.DefaultBreakerOpenDuration
+	}
+	if b.HalfOpenRequests <= 0 {
+		b.HalfOpenRequests = configs.DefaultBreakerHalfOpenRequests
+	}
+	return b
+}
+
+func (b *Breaker) State() State {
+	if b == nil {
+		return Closed
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.refresh()
+	return b.state
+}
+
+// Ready reports whether a request would be allowed, without taking a probe slot
+func (b *Breaker) Ready() bool {
+	if b == nil {
+		return true
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.refresh()
+	return b.state == Closed || (b.state == HalfOpen && b.probes < b.HalfOpenRequests)
+}
+
+// Allow reports whether a request may be sent. In half-open state it takes a probe slot, so it must be called only
+// for requests which are actually sent and whose outcome is reported by Success, Failure or Cancel.
+func (b *Breaker) Allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.refresh()
+	switch b.state {
+	case Closed:
+		return true
+	case HalfOpen:
+		if b.probes < b.HalfOpenRequests {
+			b.probes++
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Breaker) Success() {
+	if b == nil {
+		return
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	switch b.state {
+	case Closed:
+		b.consecutive = 0
+		b.window.Add(total, 1)
+	case HalfOpen:
+		b.releaseProbe()
+		b.successes++
+		if b.successes >= b.HalfOpenRequests {
+			b.setState(Closed)
+		}
+	}
+}
+
+func (b *Breaker) Failure() {
+	if b == nil {
+		return
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	switch b.state {
+	case Closed:
+		b.consecutive++
+		b.window.Add(total, 1)
+		b.window.Add(failed, 1)
+		if b.tripped() {
+			b.setState(Open)
+		}
+	case HalfOpen:
+		b.releaseProbe()
+		b.setState(Open)
+	}
+}
+
+// Cancel releases the probe slot of a request without outcome, e.g. canceled by the client
+func (b *Breaker) Cancel() {
+	if b == nil {
+		return
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.state == HalfOpen {
+		b.releaseProbe()
+	}
+}
+
+func (b *Breaker) releaseProbe() {
+	if b.probes > 0 {
+		b.probes--
+	}
+}
+
+func (b *Breaker) tripped() bool {
+	if b.MaxFailures > 0 && b.consecutive >= b.MaxFailures {
+		return true
+	}
+	if b.ErrorRate <= 0 {
+		return false
+	}
+	sums := b.window.Sums()
+	return sums[total] >= b.MinRequests && float64(sums[failed])/float64(sums[total]) >= b.ErrorRate
+}
+
+// refresh moves an open circuit to half-open once OpenDuration is passed
+func (b *Breaker) refresh() {
+	if b.state == Open && b.Now().Sub(b.openedAt) >= b.OpenDuration {
+		b.setState(HalfOpen)
+	}
+}
+
+func (b *Breaker) setState(state State) {
+	from := b.state
+	b.state = state
+	b.consecutive, b.probes, b.successes = 0, 0, 0
+	switch state {
+	case Open:
+		b.openedAt = b.Now()
+	case Closed:
+		b.window.Reset()
+	}
+	if b.OnStateChange != nil {
+		b.OnStateChange(from, state)
+	}
+}
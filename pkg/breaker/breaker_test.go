@@ -0,0 +1,111 @@
+package breaker
+
+import (
+	"github.com/samanazadi/load-balancer/configs"
+	"testing"
+	"time"
+)
+
+func newBreaker(ac configs.ActiveHealthCheck) (*Breaker, *time.Time) {
+	cfg := &configs.Config{}
+	cfg.HealthCheck.Active = ac
+	b := New(cfg)
+	now := time.Unix(1000, 0)
+	b.Now = func() time.Time { return now }
+	b.window.Now = b.Now
+	return b, &now
+}
+
+func TestNewDisabled(t *testing.T) {
+	b := New(&configs.Config{})
+	if b != nil {
+		t.Fatalf("breaker.New(no thresholds) != nil")
+	}
+	b.Failure()
+	if !b.Allow() || !b.Ready() || b.State() != Closed {
+		t.Errorf("nil Breaker doesn't allow requests")
+	}
+}
+
+func TestConsecutiveFailures(t *testing.T) {
+	b, now := newBreaker(configs.ActiveHealthCheck{MaxRetry: 3, OpenDuration: 1000, HalfOpenRequests: 2})
+	var transitions []string
+	b.OnStateChange = func(from, to State) { transitions = append(transitions, from.String()+"->"+to.String()) }
+
+	b.Failure()
+	b.Failure()
+	b.Success() // resets consecutive failures
+	b.Failure()
+	b.Failure()
+	if b.State() != Closed {
+		t.Fatalf("Breaker.State() = %s after 2 consecutive failures, want closed", b.State())
+	}
+	b.Failure()
+	if b.State() != Open || b.Allow() {
+		t.Fatalf("Breaker.State() = %s after 3 consecutive failures, want open", b.State())
+	}
+
+	// half-open allows 2 probes
+	*now = now.Add(time.Second)
+	if !b.Allow() || !b.Allow() {
+		t.Fatalf("Breaker.Allow() = false in half-open state with free probe slots")
+	}
+	if b.Allow() || b.Ready() {
+		t.Errorf("Breaker.Allow() = true in half-open state without free probe slots")
+	}
+	b.Success()
+	if b.State() != HalfOpen {
+		t.Errorf("Breaker.State() = %s after 1 of 2 successful probes, want half-open", b.State())
+	}
+	b.Success()
+	if b.State() != Closed {
+		t.Errorf("Breaker.State() = %s after successful probes, want closed", b.State())
+	}
+
+	want := []string{"closed->open", "open->half-open", "half-open->closed"}
+	if len(transitions) != len(want) {
+		t.Fatalf("Breaker transitions = %v, want %v", transitions, want)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Errorf("Breaker transitions = %v, want %v", transitions, want)
+		}
+	}
+}
+
+func TestHalfOpenFailure(t *testing.T) {
+	b, now := newBreaker(configs.ActiveHealthCheck{MaxRetry: 1, OpenDuration: 1000})
+	b.Failure()
+	*now = now.Add(time.Second)
+	if !b.Allow() {
+		t.Fatalf("Breaker.Allow() = false in half-open state")
+	}
+	b.Failure()
+	if b.State() != Open {
+		t.Errorf("Breaker.State() = %s after a failed probe, want open", b.State())
+	}
+
+	// canceled probe frees its slot
+	*now = now.Add(time.Second)
+	b.Allow()
+	b.Cancel()
+	if !b.Allow() {
+		t.Errorf("Breaker.Allow() = false after the probe is canceled")
+	}
+}
+
+func TestErrorRate(t *testing.T) {
+	b, _ := newBreaker(configs.ActiveHealthCheck{ErrorRate: 0.5, MinRequests: 10})
+	for i := 0; i < 4; i++ {
+		b.Success()
+		b.Failure()
+	}
+	if b.State() != Closed {
+		t.Fatalf("Breaker.State() = %s below min requests, want closed", b.State())
+	}
+	b.Success()
+	b.Failure()
+	if b.State() != Open {
+		t.Errorf("Breaker.State() = %s at 50%% error rate, want open", b.State())
+	}
+}
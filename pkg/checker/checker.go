@@ -0,0 +1,299 @@
+package checker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/logging"
+	"github.com/samanazadi/load-balancer/pkg/registry"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TCPType  = "tcp"
+	HTTPType = "http"
+)
+
+// ConnectionChecker checks for establishment of a connection. Canceling ctx stops a running check.
+type ConnectionChecker interface {
+	Check(ctx context.Context, u *url.URL) Result
+}
+
+// checkers are the registered checkers by name
+var checkers = registry.New[ConnectionChecker, *configs.Config]("checker")
+
+// Register adds the checker name, created by newChecker from its params decoded into P. P is a struct of params with
+// json tags, which is validated first if it implements registry.Validator. cfg.Checker is the checker being created,
+// which may be a per node or sub checker. Built-in checkers register themselves this way, others are registered by
+// init functions before New is called.
+func Register[P any](name string, newChecker func(params P, cfg *configs.Config) (ConnectionChecker, error)) {
+	registry.Register(checkers, name, newChecker)
+}
+
+// Names returns the names of the registered checkers
+func Names() []string {
+	return checkers.Names()
+}
+
+func init() {
+	Register(TCPType, func(_ registry.NoParams, cfg *configs.Config) (ConnectionChecker, error) {
+		return NewTCP(cfg), nil
+	})
+	Register(HTTPType, func(params HTTPParams, cfg *configs.Config) (ConnectionChecker, error) {
+		chk := newHTTP(params)
+		chk.Timeout = cfg.HealthCheck.Passive.Timeout.Std()
+		return chk, nil
+	})
+}
+
+func New(cfg *configs.Config) (ConnectionChecker, error) {
+	return Build(cfg.Checker, cfg)
+}
+
+// Build creates the checker described by c, e.g. a per node checker. Other settings like timeout come from cfg.
+func Build(c configs.Checker, cfg *configs.Config) (ConnectionChecker, error) {
+	sub := *cfg
+	sub.Checker = c
+	return checkers.New(c.Name, c.Params, &sub)
+}
+
+func NewTCP(cfg *configs.Config) ConnectionChecker {
+	return TCP{
+		Timeout: cfg.HealthCheck.Passive.Timeout.Std(),
+	}
+}
+
+// TCP checks by establishing a tcp connection.
+type TCP struct {
+	Timeout time.Duration
+}
+
+func (c TCP) Check(ctx context.Context, url *url.URL) Result {
+	res := begin()
+	d := net.Dialer{Timeout: c.Timeout}
+	conn, err := d.DialContext(ctx, "tcp", url.Host)
+	if err != nil {
+		return res.fail("%s", err.Error())
+	}
+	res = res.pass()
+	if err := conn.Close(); err != nil {
+		logging.Logger.Printf("cannot close connection: %s", url.String())
+	}
+	return res
+}
+
+// HTTP checks by making an HTTP request and asserting the response
+type HTTP struct {
+	Path           string
+	Method         string // GET if empty
+	Host           string // Host header, node host if empty
+	Headers        map[string]string
+	Body           string
+	ExpectedStatus StatusRanges   // 2xx if empty
+	BodyRegex      *regexp.Regexp // optional
+	JSONPath       map[string]any // optional, expected values of JSON paths in the body
+	KeyPhrase      string         // optional, body must contain it
+	Timeout        time.Duration
+}
+
+// StatusRange is an inclusive range of status codes
+type StatusRange struct {
+	Min, Max int
+}
+
+func (c HTTP) Check(ctx context.Context, url *url.URL) Result {
+	res := begin()
+	client := http.Client{
+		Timeout: c.Timeout,
+	}
+	method := c.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url.String()+c.Path, strings.NewReader(c.Body))
+	if err != nil {
+		return res.fail("%s", err.Error())
+	}
+	for k, v := range c.Headers {
+		req.Header.Set(k, v)
+	}
+	if c.Host != "" {
+		req.Host = c.Host
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return res.fail("%s", err.Error())
+	}
+	body, err := io.ReadAll(resp.Body)
+
+	defer func() {
+		err = resp.Body.Close()
+		if err != nil {
+			logging.Logger.Printf("cannot close body: %s", url.String())
+		}
+	}()
+
+	res.StatusCode = resp.StatusCode
+	res.Snippet = snippet(body)
+	if err != nil {
+		return res.fail("cannot read body: %s", err.Error())
+	}
+	if !c.statusExpected(resp.StatusCode) {
+		return res.fail("unexpected status code")
+	}
+	if !strings.Contains(string(body), c.KeyPhrase) {
+		return res.fail("body doesn't contain key phrase %q", c.KeyPhrase)
+	}
+	if c.BodyRegex != nil && !c.BodyRegex.Match(body) {
+		return res.fail("body doesn't match %s", c.BodyRegex.String())
+	}
+	if len(c.JSONPath) > 0 {
+		var doc any
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return res.fail("invalid JSON body: %s", err.Error())
+		}
+		for path, want := range c.JSONPath {
+			got, ok := lookupJSONPath(doc, path)
+			if !ok {
+				return res.fail("JSON path %s not found", path)
+			}
+			if !reflect.DeepEqual(got, want) {
+				return res.fail("JSON path %s = %v, want %v", path, got, want)
+			}
+		}
+	}
+	return res.pass()
+}
+
+func (c HTTP) statusExpected(code int) bool {
+	if len(c.ExpectedStatus) == 0 {
+		return code >= 200 && code <= 299
+	}
+	for _, r := range c.ExpectedStatus {
+		if code >= r.Min && code <= r.Max {
+			return true
+		}
+	}
+	return false
+}
+
+// HTTPParams are the params of the HTTP checker. Only "path" is mandatory.
+type HTTPParams struct {
+	Path           string            `json:"path"`
+	Method         string            `json:"method"`
+	Host           string            `json:"host"`
+	Headers        map[string]string `json:"headers"`
+	Body           string            `json:"body"`
+	ExpectedStatus StatusRanges      `json:"expectedStatus"`
+	BodyRegex      string            `json:"bodyRegex"`
+	JSONPath       map[string]any    `json:"jsonPath"`
+	KeyPhrase      string            `json:"keyPhrase"`
+}
+
+func (p HTTPParams) Validate() error {
+	if p.Path == "" {
+		return fmt.Errorf("http checker invalid path ")
+	}
+	if p.Method != "" && !validMethod(p.Method) {
+		return fmt.Errorf("http checker invalid method ")
+	}
+	for k := range p.Headers {
+		if k == "" {
+			return fmt.Errorf("http checker invalid header: %s", k)
+		}
+	}
+	if _, err := regexp.Compile(p.BodyRegex); err != nil {
+		return fmt.Errorf("http checker invalid bodyRegex: %s", err.Error())
+	}
+	for path := range p.JSONPath {
+		if _, err := parseJSONPath(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newHTTP creates an HTTP checker without timeout from validated params
+func newHTTP(p HTTPParams) HTTP {
+	chk := HTTP{
+		Path:           p.Path,
+		Method:         p.Method,
+		Host:           p.Host,
+		Headers:        p.Headers,
+		Body:           p.Body,
+		ExpectedStatus: p.ExpectedStatus,
+		JSONPath:       p.JSONPath,
+		KeyPhrase:      p.KeyPhrase,
+	}
+	if p.BodyRegex != "" {
+		chk.BodyRegex = regexp.MustCompile(p.BodyRegex)
+	}
+	return chk
+}
+
+func validMethod(method string) bool {
+	return method != "" && strings.IndexFunc(method, func(r rune) bool { return r < 'A' || r > 'Z' }) == -1
+}
+
+// StatusRanges are written as a status code, a range like "200-299", or a list of them
+type StatusRanges []StatusRange
+
+func (s *StatusRanges) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil || v == nil {
+		return err
+	}
+	ranges, err := decodeStatusRanges(v)
+	if err != nil {
+		return err
+	}
+	*s = ranges
+	return nil
+}
+
+// decodeStatusRanges decodes a status code, a range like "200-299", or a list of them
+func decodeStatusRanges(v any) ([]StatusRange, error) {
+	items, ok := v.([]any)
+	if !ok {
+		items = []any{v}
+	}
+	ranges := make([]StatusRange, 0, len(items))
+	for _, item := range items {
+		var r StatusRange
+		switch iv := item.(type) {
+		case float64:
+			r.Min, r.Max = int(iv), int(iv)
+		case string:
+			minStr, maxStr, isRange := strings.Cut(iv, "-")
+			if !isRange {
+				maxStr = minStr
+			}
+			var err1, err2 error
+			r.Min, err1 = strconv.Atoi(strings.TrimSpace(minStr))
+			r.Max, err2 = strconv.Atoi(strings.TrimSpace(maxStr))
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("http checker invalid expectedStatus: %s", iv)
+			}
+		default:
+			return nil, fmt.Errorf("http checker invalid expectedStatus: %v", item)
+		}
+		if r.Min < 100 || r.Max > 599 || r.Min > r.Max {
+			return nil, fmt.Errorf("http checker invalid expectedStatus: %v", item)
+		}
+		ranges = append(ranges, r)
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("http checker empty expectedStatus ")
+	}
+	return ranges, nil
+}
@@ -0,0 +1,293 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/logging"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	// TCP
+	cfg := &configs.Config{Checker: configs.Checker{Name: TCPType}}
+	chk, err := New(cfg)
+	if _, ok := chk.(TCP); !ok {
+		t.Errorf("checker.New(TCPType) != TCP")
+	}
+	if err != nil {
+		t.Errorf("checker.New(TCPType) returns error")
+	}
+	// HTTP
+	cfg = &configs.Config{Checker: configs.Checker{Name: HTTPType,
+		Params: map[string]any{"path": "path", "keyPhrase": "keyPhrase"}}}
+	chk, err = New(cfg)
+	if _, ok := chk.(HTTP); !ok {
+		t.Errorf("checker.New(HTTPType) != HTTP")
+	}
+	if err != nil {
+		t.Errorf("checker.New(HTTPType) returns error")
+	}
+	// invalid type
+	cfg = &configs.Config{Checker: configs.Checker{Name: "invalid"}}
+	chk, err = New(cfg)
+	if err == nil {
+		t.Errorf("checker.New(invalid type) doesn't return error")
+	}
+}
+
+func TestTCPCheckAvailableServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	hc := TCP{
+		Timeout: time.Second,
+	}
+
+	u, _ := url.Parse(server.URL)
+	if got := hc.Check(context.Background(), u).Healthy; !got {
+		t.Errorf("TCP{timeout: %d} should have succeeded", 1)
+	}
+}
+
+func TestTCPCheckUnavailableServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	hc := TCP{
+		Timeout: time.Second,
+	}
+
+	u, _ := url.Parse(server.URL + "1") // distort address to be unavailable
+	if got := hc.Check(context.Background(), u).Healthy; got {
+		t.Errorf("TCP{timeout: %d} should have failed", 1)
+	}
+}
+
+func TestHTTPCheckAvailableServer(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		keyPhrase   string
+		timeout     time.Duration
+		serverPath  string
+		serverResp  string
+		serverDelay int
+		want        bool
+	}{
+		{
+			name:        "AllCorrect",
+			path:        "/ping",
+			keyPhrase:   "pong",
+			timeout:     10 * time.Second,
+			serverPath:  "/ping",
+			serverResp:  "pong",
+			serverDelay: 0,
+			want:        true,
+		},
+		{
+			name:        "IncorrectPath",
+			path:        "/test",
+			keyPhrase:   "pong",
+			timeout:     10 * time.Second,
+			serverPath:  "/ping",
+			serverResp:  "pong",
+			serverDelay: 0,
+			want:        false,
+		},
+		{
+			name:        "IncorrectKeyPhrase",
+			path:        "/ping",
+			keyPhrase:   "key",
+			timeout:     10 * time.Second,
+			serverPath:  "/ping",
+			serverResp:  "pong",
+			serverDelay: 0,
+			want:        false,
+		},
+		{
+			name:        "ExceedingTimeout",
+			path:        "/ping",
+			keyPhrase:   "key",
+			timeout:     time.Second,
+			serverPath:  "/ping",
+			serverResp:  "pong",
+			serverDelay: 2,
+			want:        false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == test.serverPath {
+					time.Sleep(time.Duration(test.serverDelay) * time.Second)
+					fmt.Fprint(rw, test.serverResp)
+				}
+			}))
+			defer server.Close()
+			hc := HTTP{
+				Path:      test.path,
+				KeyPhrase: test.keyPhrase,
+				Timeout:   test.timeout,
+			}
+			u, _ := url.Parse(server.URL)
+
+			if got := hc.Check(context.Background(), u).Healthy; got != test.want {
+				var shouldFail string
+				if test.want {
+					shouldFail = "succeeded"
+				} else {
+					shouldFail = "failed"
+				}
+
+				t.Errorf(
+					"HTTP{path: %s, keyPhrase: %s, timeout: %d}, Server{path: %s, response: %s, delay: %ds} should have %s",
+					test.path, test.keyPhrase, test.timeout, test.serverPath, test.serverResp, test.serverDelay, shouldFail)
+			}
+		})
+	}
+}
+
+func TestHTTPCheckUnavailableServer(t *testing.T) {
+	hc := HTTP{
+		Path:      "/ping",
+		KeyPhrase: "pong",
+		Timeout:   time.Second,
+	}
+	u, _ := url.Parse("unavailable")
+	if got := hc.Check(context.Background(), u).Healthy; got {
+		t.Errorf("HTTP.Check(unavailable server) = %t", got)
+	}
+}
+
+// newHTTPChecker creates an http checker of params by New
+func newHTTPChecker(params map[string]any) (HTTP, error) {
+	chk, err := New(&configs.Config{Checker: configs.Checker{Name: HTTPType, Params: params}})
+	if err != nil {
+		return HTTP{}, err
+	}
+	return chk.(HTTP), nil
+}
+
+func TestHTTPParams(t *testing.T) {
+	params := map[string]any{
+		"path":      "some-path",
+		"keyPhrase": "key",
+	}
+	chk, err := newHTTPChecker(params)
+
+	if err != nil {
+		t.Errorf("checker.New(http, map[path=some-path, key]) cannot be decoded")
+	}
+
+	if chk.Path != "some-path" {
+		t.Errorf("checker.New(http, map[path=some-path]).path = %s", chk.Path)
+	}
+	if chk.KeyPhrase != "key" {
+		t.Errorf("checker.New(http, map[keyPhrase=key]).keyPhrase = %s", chk.KeyPhrase)
+	}
+
+	// all params
+	params = map[string]any{
+		"path":           "/health",
+		"method":         "POST",
+		"host":           "example.com",
+		"headers":        map[string]any{"Authorization": "token"},
+		"body":           "{}",
+		"expectedStatus": []any{200.0, "300-302"},
+		"bodyRegex":      "^ok",
+		"jsonPath":       map[string]any{"$.status": "UP"},
+	}
+	chk, err = newHTTPChecker(params)
+	if err != nil {
+		t.Fatalf("checker.New(http, all params) returns error: %s", err)
+	}
+	if chk.Method != "POST" || chk.Host != "example.com" || chk.Headers["Authorization"] != "token" ||
+		chk.Body != "{}" || chk.BodyRegex == nil || chk.JSONPath["$.status"] != "UP" || chk.KeyPhrase != "" {
+		t.Errorf("checker.New(http, all params) = %+v", chk)
+	}
+	if len(chk.ExpectedStatus) != 2 || chk.ExpectedStatus[1] != (StatusRange{300, 302}) {
+		t.Errorf("checker.New(http, expectedStatus).ExpectedStatus = %v", chk.ExpectedStatus)
+	}
+}
+
+func TestHTTPParamsInvalid(t *testing.T) {
+	tests := map[string]map[string]any{
+		"NoPath":           {},
+		"Method":           {"path": "/", "method": "get"},
+		"Headers":          {"path": "/", "headers": map[string]any{"X": 1.0}},
+		"StatusType":       {"path": "/", "expectedStatus": true},
+		"StatusRange":      {"path": "/", "expectedStatus": "299-200"},
+		"StatusOutOfRange": {"path": "/", "expectedStatus": 600.0},
+		"StatusEmpty":      {"path": "/", "expectedStatus": []any{}},
+		"BodyRegex":        {"path": "/", "bodyRegex": "("},
+		"JSONPath":         {"path": "/", "jsonPath": map[string]any{"$.a[x]": 1.0}},
+		"KeyPhrase":        {"path": "/", "keyPhrase": 1.0},
+	}
+	for name, params := range tests {
+		params := params
+		t.Run(name, func(t *testing.T) {
+			if _, err := newHTTPChecker(params); err == nil {
+				t.Errorf("checker.New(http, %v) doesn't return error", params)
+			}
+		})
+	}
+}
+
+func TestHTTPCheckAssertions(t *testing.T) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.Host != "health.local" || r.Header.Get("X-Token") != "secret" ||
+			string(body) != "ping" {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		rw.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(rw, `{"status": "UP", "checks": [{"name": "db", "up": true}], "version": 3}`)
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	valid := func() HTTP {
+		return HTTP{
+			Path:    "/health",
+			Method:  http.MethodPost,
+			Host:    "health.local",
+			Headers: map[string]string{"X-Token": "secret"},
+			Body:    "ping",
+			Timeout: time.Second,
+		}
+	}
+	tests := []struct {
+		name   string
+		modify func(*HTTP)
+		want   bool
+	}{
+		{"AllCorrect", func(*HTTP) {}, true},
+		{"WrongHeader", func(c *HTTP) { c.Headers = nil }, false},
+		{"ExpectedStatusRange", func(c *HTTP) { c.ExpectedStatus = []StatusRange{{200, 202}} }, true},
+		{"UnexpectedStatus", func(c *HTTP) { c.ExpectedStatus = []StatusRange{{200, 200}} }, false},
+		{"BodyRegex", func(c *HTTP) { c.BodyRegex = regexp.MustCompile(`"version": \d+`) }, true},
+		{"BodyRegexMismatch", func(c *HTTP) { c.BodyRegex = regexp.MustCompile(`DOWN`) }, false},
+		{"JSONPath", func(c *HTTP) {
+			c.JSONPath = map[string]any{"$.status": "UP", "checks[0].up": true, "version": 3.0}
+		}, true},
+		{"JSONPathMismatch", func(c *HTTP) { c.JSONPath = map[string]any{"$.checks[0].name": "cache"} }, false},
+		{"JSONPathMissing", func(c *HTTP) { c.JSONPath = map[string]any{"$.checks[1].name": "db"} }, false},
+		{"KeyPhrase", func(c *HTTP) { c.KeyPhrase = "UP" }, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hc := valid()
+			test.modify(&hc)
+			if got := hc.Check(context.Background(), u).Healthy; got != test.want {
+				t.Errorf("HTTP%+v.Check() = %t, want %t", hc, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"github.com/samanazadi/load-balancer/configs"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+const (
+	AllType = "all"
+	AnyType = "any"
+)
+
+// Composite combines sub checkers. With AllType every sub check must pass, with AnyType one is enough.
+type Composite struct {
+	Mode   string
+	Checks []SubCheck
+}
+
+// SubCheck is a checker of a composite, optionally probing another port of the node
+type SubCheck struct {
+	Name    string // for diagnostics, checker name by default
+	Port    int    // overrides the port of the target
+	Checker ConnectionChecker
+}
+
+// CompositeParams are the params of composite checkers
+type CompositeParams struct {
+	Checks []SubCheckParams `json:"checks"`
+}
+
+// SubCheckParams describe a checker of a composite like {"name": "http", "params": {"path": "/ready"}, "port": 9000}
+type SubCheckParams struct {
+	Name   string         `json:"name"`
+	Params map[string]any `json:"params"`
+	Label  string         `json:"label"` // checker name by default
+	Port   int            `json:"port"`  // overrides the port of the target
+}
+
+func (p CompositeParams) Validate() error {
+	if len(p.Checks) == 0 {
+		return fmt.Errorf("invalid checks ")
+	}
+	for i, c := range p.Checks {
+		if c.Name == "" {
+			return fmt.Errorf("invalid name of check: %d", i)
+		}
+		if c.Port < 0 || c.Port > 65535 {
+			return fmt.Errorf("invalid port of check: %d", i)
+		}
+	}
+	return nil
+}
+
+func init() {
+	for _, mode := range []string{AllType, AnyType} {
+		mode := mode
+		Register(mode, func(params CompositeParams, cfg *configs.Config) (ConnectionChecker, error) {
+			chk, err := newComposite(mode, params, cfg)
+			if err != nil {
+				return nil, err
+			}
+			return chk, nil
+		})
+	}
+}
+
+// Check runs every sub check concurrently. The result holds the result of each sub check.
+func (c Composite) Check(ctx context.Context, u *url.URL) Result {
+	res := begin()
+	res.Checks = make([]Result, len(c.Checks))
+	var wg sync.WaitGroup
+	for i, sc := range c.Checks {
+		wg.Add(1)
+		go func(i int, sc SubCheck) {
+			defer wg.Done()
+			target := u
+			if sc.Port != 0 {
+				t := *u
+				t.Host = net.JoinHostPort(u.Hostname(), strconv.Itoa(sc.Port))
+				target = &t
+			}
+			res.Checks[i] = sc.Checker.Check(ctx, target)
+			res.Checks[i].Name = sc.Name
+		}(i, sc)
+	}
+	wg.Wait()
+
+	passed := 0
+	for _, r := range res.Checks {
+		if r.Healthy {
+			passed++
+		}
+	}
+	switch {
+	case c.Mode == AllType && passed < len(c.Checks):
+		return res.fail("%d of %d checks failed", len(c.Checks)-passed, len(c.Checks))
+	case c.Mode == AnyType && passed == 0:
+		return res.fail("all %d checks failed", len(c.Checks))
+	}
+	return res.pass()
+}
+
+// newComposite builds the sub checkers of validated params
+func newComposite(mode string, params CompositeParams, cfg *configs.Config) (Composite, error) {
+	chk := Composite{Mode: mode, Checks: make([]SubCheck, 0, len(params.Checks))}
+	for i, c := range params.Checks {
+		sc := SubCheck{Name: c.Label, Port: c.Port}
+		if sc.Name == "" {
+			sc.Name = c.Name
+		}
+		var err error
+		if sc.Checker, err = Build(configs.Checker{Name: c.Name, Params: c.Params}, cfg); err != nil {
+			return Composite{}, fmt.Errorf("%s checker check %d: %s", mode, i, err.Error())
+		}
+		chk.Checks = append(chk.Checks, sc)
+	}
+	return chk, nil
+}
@@ -0,0 +1,136 @@
+package checker
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/samanazadi/load-balancer/configs"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// portChecker is healthy if the port of the target is in the set
+type portChecker map[string]bool
+
+func (c portChecker) Check(_ context.Context, u *url.URL) Result {
+	return Result{Healthy: c[u.Port()]}
+}
+
+// summary returns the name and health of each sub check result, recursively
+func summary(results []Result) []Result {
+	var s []Result
+	for _, r := range results {
+		s = append(s, Result{Name: r.Name, Healthy: r.Healthy, Checks: summary(r.Checks)})
+	}
+	return s
+}
+
+func TestCompositeCheck(t *testing.T) {
+	up := portChecker{"8001": true, "5432": true}
+	u, _ := url.Parse("http://10.0.0.1:8001")
+
+	tests := []struct {
+		name        string
+		chk         Composite
+		want        bool
+		wantResults []Result
+	}{
+		{
+			name:        "AllHealthy",
+			chk:         Composite{Mode: AllType, Checks: []SubCheck{{Name: "http", Checker: up}, {Name: "db", Port: 5432, Checker: up}}},
+			want:        true,
+			wantResults: []Result{{Name: "http", Healthy: true}, {Name: "db", Healthy: true}},
+		},
+		{
+			name:        "AllUnhealthy",
+			chk:         Composite{Mode: AllType, Checks: []SubCheck{{Name: "http", Checker: up}, {Name: "db", Port: 3306, Checker: up}}},
+			want:        false,
+			wantResults: []Result{{Name: "http", Healthy: true}, {Name: "db", Healthy: false}},
+		},
+		{
+			name:        "AnyHealthy",
+			chk:         Composite{Mode: AnyType, Checks: []SubCheck{{Name: "a", Port: 1, Checker: up}, {Name: "b", Checker: up}}},
+			want:        true,
+			wantResults: []Result{{Name: "a", Healthy: false}, {Name: "b", Healthy: true}},
+		},
+		{
+			name: "Nested",
+			chk: Composite{Mode: AllType, Checks: []SubCheck{
+				{Name: "http", Checker: up},
+				{Name: "any", Checker: Composite{Mode: AnyType, Checks: []SubCheck{
+					{Name: "mysql", Port: 3306, Checker: up},
+					{Name: "pg", Port: 5432, Checker: up},
+				}}},
+			}},
+			want: true,
+			wantResults: []Result{
+				{Name: "http", Healthy: true},
+				{Name: "any", Healthy: true, Checks: []Result{{Name: "mysql"}, {Name: "pg", Healthy: true}}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := test.chk.Check(context.Background(), u)
+			if got := summary(res.Checks); res.Healthy != test.want || !reflect.DeepEqual(got, test.wantResults) {
+				t.Errorf("Composite.Check() = %t %v, want %t %v", res.Healthy, got, test.want, test.wantResults)
+			}
+			if !res.Healthy && res.Reason == "" {
+				t.Errorf("Composite.Check() failed without a reason")
+			}
+		})
+	}
+}
+
+func TestCompositeParams(t *testing.T) {
+	tree := `{"checks": [
+		{"name": "tcp", "port": 5432, "label": "db"},
+		{"name": "any", "params": {"checks": [
+			{"name": "http", "params": {"path": "/ready"}},
+			{"name": "tcp"}
+		]}}
+	]}`
+	var params map[string]any
+	if err := json.Unmarshal([]byte(tree), &params); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &configs.Config{Checker: configs.Checker{Name: AllType, Params: params}}
+	chk, err := New(cfg)
+	if err != nil {
+		t.Fatalf("checker.New(all) returned error: %s", err)
+	}
+	c, ok := chk.(Composite)
+	if !ok || c.Mode != AllType || len(c.Checks) != 2 {
+		t.Fatalf("checker.New(all) = %+v, want a composite of 2 checks", chk)
+	}
+	if c.Checks[0].Name != "db" || c.Checks[0].Port != 5432 {
+		t.Errorf("checker.New(all) first check = %+v, want db on port 5432", c.Checks[0])
+	}
+	sub, ok := c.Checks[1].Checker.(Composite)
+	if !ok || sub.Mode != AnyType || len(sub.Checks) != 2 {
+		t.Fatalf("checker.New(all) second check = %+v, want a composite of 2 checks", c.Checks[1].Checker)
+	}
+	if h, ok := sub.Checks[0].Checker.(HTTP); !ok || h.Path != "/ready" {
+		t.Errorf("checker.New(all) nested http check = %+v, want path /ready", sub.Checks[0].Checker)
+	}
+}
+
+func TestCompositeParamsInvalid(t *testing.T) {
+	tests := []map[string]any{
+		{},
+		{"checks": []any{}},
+		{"checks": []any{"tcp"}},
+		{"checks": []any{map[string]any{}}},
+		{"checks": []any{map[string]any{"name": "invalid"}}},
+		{"checks": []any{map[string]any{"name": "tcp", "port": float64(70000)}}},
+		{"checks": []any{map[string]any{"name": "tcp", "params": "x"}}},
+		{"checks": []any{map[string]any{"name": "http"}}},
+		{"checks": []any{map[string]any{"name": "any"}}},
+	}
+	for _, params := range tests {
+		cfg := &configs.Config{Checker: configs.Checker{Name: AllType, Params: params}}
+		if _, err := New(cfg); err == nil {
+			t.Errorf("checker.New(all, %v) doesn't return error", params)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/samanazadi/load-balancer/configs"
+	"net/url"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const ExecType = "exec"
+
+// maxExecOutput is the number of captured output bytes of an exec check
+const maxExecOutput = 4096
+
+// Exec checks by running a command which exits with 0 for a healthy node.
+// The node is passed in LB_NODE_URL, LB_NODE_SCHEME, LB_NODE_HOST and LB_NODE_PORT environment variables.
+type Exec struct {
+	Command []string
+	Env     map[string]string // extra environment variables
+	Timeout time.Duration
+}
+
+func init() {
+	Register(ExecType, func(params ExecParams, cfg *configs.Config) (ConnectionChecker, error) {
+		return Exec{Command: params.Command, Env: params.Env, Timeout: cfg.HealthCheck.Passive.Timeout.Std()}, nil
+	})
+}
+
+func (c Exec) Check(ctx context.Context, url *url.URL) Result {
+	res := begin()
+	output, err := c.Run(ctx, url)
+	res.Snippet = snippet(output)
+	if err != nil {
+		return res.fail("%s", err.Error())
+	}
+	return res.pass()
+}
+
+// Run runs the command for the node and returns its combined output, truncated to 4KiB. Canceling ctx kills the
+// command.
+func (c Exec) Run(parent context.Context, url *url.URL) ([]byte, error) {
+	ctx := parent
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...)
+	cmd.Env = append(os.Environ(),
+		"LB_NODE_URL="+url.String(),
+		"LB_NODE_SCHEME="+url.Scheme,
+		"LB_NODE_HOST="+url.Hostname(),
+		"LB_NODE_PORT="+nodePort(url),
+	)
+	for k, v := range c.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	out := &limitedBuffer{limit: maxExecOutput}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	// don't wait for pipes held by children of a killed command
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	switch {
+	case parent.Err() != nil:
+		err = fmt.Errorf("canceled: %s", parent.Err().Error())
+	case ctx.Err() != nil:
+		err = fmt.Errorf("timed out after %s", c.Timeout)
+	}
+	return out.Bytes(), err
+}
+
+// nodePort returns the port of the URL, or the default port of its scheme
+func nodePort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if u.Scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the rest
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room > 0 {
+		if len(p) > room {
+			b.buf.Write(p[:room])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// ExecParams are the params of the exec checker. "command" is mandatory and is a list of the program and its
+// arguments. "env" is an optional object of extra environment variables.
+type ExecParams struct {
+	Command []string          `json:"command"`
+	Env     map[string]string `json:"env"`
+}
+
+func (p ExecParams) Validate() error {
+	if len(p.Command) == 0 {
+		return fmt.Errorf("exec checker invalid command ")
+	}
+	if p.Command[0] == "" {
+		return fmt.Errorf("exec checker empty program ")
+	}
+	for k := range p.Env {
+		if k == "" {
+			return fmt.Errorf("exec checker invalid env: %s", k)
+		}
+	}
+	return nil
+}
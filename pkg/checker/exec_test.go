@@ -0,0 +1,92 @@
+package checker
+
+import (
+	"context"
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/logging"
+	"io"
+	"log"
+	"net/url"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecRun(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	logging.Logger = log.New(io.Discard, "", 0)
+	u, _ := url.Parse("http://10.0.0.1:8001")
+
+	tests := []struct {
+		name       string
+		script     string
+		want       bool
+		wantOutput string
+	}{
+		{"Healthy", `echo "$LB_NODE_HOST $LB_NODE_PORT $LB_NODE_SCHEME $EXTRA"`, true, "10.0.0.1 8001 http extra\n"},
+		{"Unhealthy", `echo down >&2; exit 2`, false, "down\n"},
+		{"Timeout", `exec sleep 5`, false, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			env := map[string]string{"EXTRA": "extra"}
+			chk := Exec{Command: []string{"sh", "-c", test.script}, Env: env, Timeout: time.Second}
+			start := time.Now()
+			output, err := chk.Run(context.Background(), u)
+			if (err == nil) != test.want || string(output) != test.wantOutput {
+				t.Errorf("Exec.Run(%s) = %q %v, want %q healthy %t", test.script, output, err, test.wantOutput, test.want)
+			}
+			if elapsed := time.Since(start); elapsed > 3*time.Second {
+				t.Errorf("Exec.Run(%s) took %s, want at most the timeout", test.script, elapsed)
+			}
+			if got := chk.Check(context.Background(), u).Healthy; got != test.want {
+				t.Errorf("Exec.Check(%s) = %t, want %t", test.script, got, test.want)
+			}
+		})
+	}
+}
+
+func TestExecRunOutputLimit(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	u, _ := url.Parse("http://10.0.0.1")
+	chk := Exec{Command: []string{"sh", "-c", `i=0; while [ $i -lt 1000 ]; do echo 0123456789; i=$((i+1)); done; echo $LB_NODE_PORT`}}
+	output, err := chk.Run(context.Background(), u)
+	if err != nil || len(output) != maxExecOutput || !strings.HasPrefix(string(output), "0123456789\n") {
+		t.Errorf("Exec.Run(long output) = %d bytes %v, want %d bytes", len(output), err, maxExecOutput)
+	}
+}
+
+func TestExecParams(t *testing.T) {
+	cfg := &configs.Config{Checker: configs.Checker{Name: ExecType,
+		Params: map[string]any{"command": []any{"/bin/check", "-v"}, "env": map[string]any{"A": "1"}}}}
+	cfg.HealthCheck.Passive.Timeout = configs.Seconds(3)
+	chk, err := New(cfg)
+	if err != nil {
+		t.Fatalf("checker.New(ExecType) returned error: %s", err)
+	}
+	e, ok := chk.(Exec)
+	if !ok || len(e.Command) != 2 || e.Command[1] != "-v" || e.Env["A"] != "1" || e.Timeout != 3*time.Second {
+		t.Errorf("checker.New(ExecType) = %+v", chk)
+	}
+
+	invalid := []map[string]any{
+		{},
+		{"command": "/bin/check"},
+		{"command": []any{}},
+		{"command": []any{""}},
+		{"command": []any{"/bin/check", 1.0}},
+		{"command": []any{"/bin/check"}, "env": "A=1"},
+		{"command": []any{"/bin/check"}, "env": map[string]any{"A": 1.0}},
+	}
+	for _, params := range invalid {
+		cfg := &configs.Config{Checker: configs.Checker{Name: ExecType, Params: params}}
+		if _, err := New(cfg); err == nil {
+			t.Errorf("checker.New(exec, %v) doesn't return error", params)
+		}
+	}
+}
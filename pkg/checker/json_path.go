@@ -0,0 +1,64 @@
+package checker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseJSONPath splits a path like "$.checks[0].status" (leading "$." is optional) into object keys and
+// array indexes
+func parseJSONPath(path string) ([]any, error) {
+	p := strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if p == "" {
+		return nil, fmt.Errorf("http checker invalid jsonPath: %s", path)
+	}
+	var steps []any
+	for _, part := range strings.Split(p, ".") {
+		if part == "" {
+			return nil, fmt.Errorf("http checker invalid jsonPath: %s", path)
+		}
+		key, rest, _ := strings.Cut(part, "[")
+		if key != "" {
+			steps = append(steps, key)
+		}
+		for rest != "" {
+			index, after, ok := strings.Cut(rest, "]")
+			i, err := strconv.Atoi(index)
+			if !ok || err != nil || i < 0 || (after != "" && after[0] != '[') {
+				return nil, fmt.Errorf("http checker invalid jsonPath: %s", path)
+			}
+			steps = append(steps, i)
+			rest = strings.TrimPrefix(after, "[")
+		}
+	}
+	return steps, nil
+}
+
+// lookupJSONPath returns the value at path in a decoded JSON document
+func lookupJSONPath(doc any, path string) (any, bool) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, false
+	}
+	v := doc
+	for _, step := range steps {
+		switch s := step.(type) {
+		case string:
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if v, ok = obj[s]; !ok {
+				return nil, false
+			}
+		case int:
+			arr, ok := v.([]any)
+			if !ok || s >= len(arr) {
+				return nil, false
+			}
+			v = arr[s]
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,222 @@
+package checker
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/logging"
+	"github.com/samanazadi/load-balancer/pkg/registry"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	RedisType    = "redis"
+	PostgresType = "postgres"
+	MySQLType    = "mysql"
+	SMTPType     = "smtp"
+)
+
+// default ports of the protocols, used when the node URL has none
+const (
+	redisPort    = "6379"
+	postgresPort = "5432"
+	mysqlPort    = "3306"
+	smtpPort     = "25"
+)
+
+func init() {
+	Register(RedisType, func(params RedisParams, cfg *configs.Config) (ConnectionChecker, error) {
+		return Redis{Password: params.Password, Timeout: cfg.HealthCheck.Passive.Timeout.Std()}, nil
+	})
+	Register(PostgresType, func(_ registry.NoParams, cfg *configs.Config) (ConnectionChecker, error) {
+		return NewPostgres(cfg), nil
+	})
+	Register(MySQLType, func(_ registry.NoParams, cfg *configs.Config) (ConnectionChecker, error) {
+		return NewMySQL(cfg), nil
+	})
+	Register(SMTPType, func(_ registry.NoParams, cfg *configs.Config) (ConnectionChecker, error) {
+		return NewSMTP(cfg), nil
+	})
+}
+
+// probe dials the node, on defaultPort if its URL has no port, and runs a protocol exchange on the connection, all
+// within the timeout. Canceling ctx interrupts the exchange.
+func probe(ctx context.Context, u *url.URL, defaultPort string, timeout time.Duration,
+	exchange func(net.Conn, *bufio.Reader) error) Result {
+	res := begin()
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "tcp", hostPort(u, defaultPort))
+	if err != nil {
+		return res.fail("%s", err.Error())
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			logging.Logger.Printf("cannot close connection: %s", u.String())
+		}
+	}()
+	if timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			return res.fail("%s", err.Error())
+		}
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+	if err := exchange(conn, bufio.NewReader(conn)); err != nil {
+		return res.fail("%s", err.Error())
+	}
+	return res.pass()
+}
+
+// hostPort returns the host and port of u, defaultPort if u has no port
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+// Redis checks by sending PING, after AUTH if a password is set, and expecting PONG
+type Redis struct {
+	Password string // optional
+	Timeout  time.Duration
+}
+
+// RedisParams are the params of the Redis checker
+type RedisParams struct {
+	Password string `json:"password"` // optional
+}
+
+func (c Redis) Check(ctx context.Context, url *url.URL) Result {
+	return probe(ctx, url, redisPort, c.Timeout, func(conn net.Conn, r *bufio.Reader) error {
+		if c.Password != "" {
+			if err := redisCommand(conn, r, "+OK", "AUTH", c.Password); err != nil {
+				return err
+			}
+		}
+		return redisCommand(conn, r, "+PONG", "PING")
+	})
+}
+
+// redisCommand sends a RESP command and expects a simple string reply
+func redisCommand(conn net.Conn, r *bufio.Reader, want string, args ...string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(conn, b.String()); err != nil {
+		return err
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if line = strings.TrimRight(line, "\r\n"); line != want {
+		return fmt.Errorf("unexpected reply to %s: %q", args[0], line)
+	}
+	return nil
+}
+
+// Postgres checks by sending an SSLRequest and expecting the one byte answer of a PostgreSQL server
+type Postgres struct {
+	Timeout time.Duration
+}
+
+func NewPostgres(cfg *configs.Config) ConnectionChecker {
+	return Postgres{Timeout: cfg.HealthCheck.Passive.Timeout.Std()}
+}
+
+// postgresSSLRequestCode is the protocol version number of an SSLRequest message
+const postgresSSLRequestCode = 80877103
+
+func (c Postgres) Check(ctx context.Context, url *url.URL) Result {
+	return probe(ctx, url, postgresPort, c.Timeout, func(conn net.Conn, r *bufio.Reader) error {
+		msg := make([]byte, 8)
+		binary.BigEndian.PutUint32(msg[0:4], 8)
+		binary.BigEndian.PutUint32(msg[4:8], postgresSSLRequestCode)
+		if _, err := conn.Write(msg); err != nil {
+			return err
+		}
+		answer, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if answer != 'S' && answer != 'N' {
+			return fmt.Errorf("unexpected SSLRequest answer: %q", answer)
+		}
+		return nil
+	})
+}
+
+// MySQL checks by reading the initial handshake packet of the server
+type MySQL struct {
+	Timeout time.Duration
+}
+
+func NewMySQL(cfg *configs.Config) ConnectionChecker {
+	return MySQL{Timeout: cfg.HealthCheck.Passive.Timeout.Std()}
+}
+
+func (c MySQL) Check(ctx context.Context, url *url.URL) Result {
+	return probe(ctx, url, mysqlPort, c.Timeout, func(_ net.Conn, r *bufio.Reader) error {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return err
+		}
+		length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+		if length == 0 || header[3] != 0 {
+			return errors.New("invalid greeting packet header")
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return err
+		}
+		switch payload[0] {
+		case 0x0a: // protocol version 10
+			return nil
+		case 0xff: // error packet, e.g. too many connections
+			if len(payload) > 3 {
+				return fmt.Errorf("server error %d: %s", binary.LittleEndian.Uint16(payload[1:3]), payload[3:])
+			}
+			return errors.New("server error")
+		default:
+			return fmt.Errorf("unsupported protocol version: %d", payload[0])
+		}
+	})
+}
+
+// SMTP checks by reading the 220 greeting banner of the server and quitting
+type SMTP struct {
+	Timeout time.Duration
+}
+
+func NewSMTP(cfg *configs.Config) ConnectionChecker {
+	return SMTP{Timeout: cfg.HealthCheck.Passive.Timeout.Std()}
+}
+
+func (c SMTP) Check(ctx context.Context, url *url.URL) Result {
+	return probe(ctx, url, smtpPort, c.Timeout, func(conn net.Conn, r *bufio.Reader) error {
+		// the banner may be multiline like "220-first\r\n220 last\r\n"
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return err
+			}
+			if !strings.HasPrefix(line, "220") {
+				return fmt.Errorf("unexpected banner: %q", strings.TrimRight(line, "\r\n"))
+			}
+			if len(line) < 4 || line[3] != '-' {
+				break
+			}
+		}
+		_, err := io.WriteString(conn, "QUIT\r\n")
+		return err
+	})
+}
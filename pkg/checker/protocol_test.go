@@ -0,0 +1,158 @@
+package checker
+
+import (
+	"bufio"
+	"context"
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/logging"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const timeout = time.Second
+
+// fakeServer serves every connection with the script and returns the server URL
+func fakeServer(t *testing.T, script func(net.Conn, *bufio.Reader)) *url.URL {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				script(conn, bufio.NewReader(conn))
+			}()
+		}
+	}()
+	return &url.URL{Scheme: "tcp", Host: ln.Addr().String()}
+}
+
+// reply reads the request bytes and writes the response, for each request in order
+func reply(request int, response string, more ...any) func(net.Conn, *bufio.Reader) {
+	return func(conn net.Conn, r *bufio.Reader) {
+		if _, err := io.ReadFull(r, make([]byte, request)); err != nil {
+			return
+		}
+		io.WriteString(conn, response)
+		if len(more) >= 2 {
+			reply(more[0].(int), more[1].(string), more[2:]...)(conn, r)
+		}
+	}
+}
+
+func TestProtocolCheckers(t *testing.T) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	ping := len("*1\r\n$4\r\nPING\r\n")
+	auth := len("*2\r\n$4\r\nAUTH\r\n$6\r\nsecret\r\n")
+	greeting := "\x0a8.0.36\x00"
+	mysqlError := "\xff\x10\x04Too many connections"
+
+	tests := []struct {
+		name   string
+		chk    ConnectionChecker
+		script func(net.Conn, *bufio.Reader)
+		want   bool
+	}{
+		{"RedisPong", Redis{Timeout: timeout}, reply(ping, "+PONG\r\n"), true},
+		{"RedisLoading", Redis{Timeout: timeout}, reply(ping, "-LOADING Redis is loading the dataset in memory\r\n"), false},
+		{"RedisAuth", Redis{Password: "secret", Timeout: timeout}, reply(auth, "+OK\r\n", ping, "+PONG\r\n"), true},
+		{"RedisAuthFailed", Redis{Password: "secret", Timeout: timeout}, reply(auth, "-WRONGPASS invalid password\r\n"), false},
+		{"PostgresSSL", Postgres{Timeout: timeout}, reply(8, "S"), true},
+		{"PostgresNoSSL", Postgres{Timeout: timeout}, reply(8, "N"), true},
+		{"PostgresHTTP", Postgres{Timeout: timeout}, reply(8, "HTTP/1.1 400 Bad Request\r\n"), false},
+		{"MySQLGreeting", MySQL{Timeout: timeout}, reply(0, "\x08\x00\x00\x00"+greeting), true},
+		{"MySQLError", MySQL{Timeout: timeout}, reply(0, "\x17\x00\x00\x00"+mysqlError), false},
+		{"MySQLSilent", MySQL{Timeout: timeout}, reply(0, ""), false},
+		{"SMTPBanner", SMTP{Timeout: timeout}, reply(0, "220 mail.example.com ESMTP\r\n"), true},
+		{"SMTPMultilineBanner", SMTP{Timeout: timeout}, reply(0, "220-mail.example.com\r\n220 ESMTP\r\n"), true},
+		{"SMTPBusy", SMTP{Timeout: timeout}, reply(0, "554 no service\r\n"), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u := fakeServer(t, test.script)
+			if got := test.chk.Check(context.Background(), u).Healthy; got != test.want {
+				t.Errorf("%T.Check() = %t, want %t", test.chk, got, test.want)
+			}
+		})
+	}
+}
+
+func TestProtocolCheckersUnavailableServer(t *testing.T) {
+	u := fakeServer(t, func(net.Conn, *bufio.Reader) {})
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	u.Host = ln.Addr().String()
+	ln.Close()
+	for _, chk := range []ConnectionChecker{
+		Redis{Timeout: timeout}, Postgres{Timeout: timeout}, MySQL{Timeout: timeout}, SMTP{Timeout: timeout},
+	} {
+		if chk.Check(context.Background(), u).Healthy {
+			t.Errorf("%T.Check(closed port) = true, want false", chk)
+		}
+	}
+}
+
+func TestHostPort(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"redis://cache:6380", "cache:6380"},
+		{"redis://cache", "cache:6379"},
+		{"redis://[::1]", "[::1]:6379"},
+	}
+	for _, test := range tests {
+		u, _ := url.Parse(test.url)
+		if got := hostPort(u, redisPort); got != test.want {
+			t.Errorf("hostPort(%s, %s) = %s, want %s", test.url, redisPort, got, test.want)
+		}
+	}
+}
+
+func TestNewProtocolCheckers(t *testing.T) {
+	for _, name := range []string{RedisType, PostgresType, MySQLType, SMTPType} {
+		if _, err := New(&configs.Config{Checker: configs.Checker{Name: name}}); err != nil {
+			t.Errorf("checker.New(%s) returns error: %s", name, err)
+		}
+	}
+	cfg := &configs.Config{Checker: configs.Checker{Name: RedisType, Params: map[string]any{"password": "secret"}}}
+	if chk, err := New(cfg); err != nil || chk.(Redis).Password != "secret" {
+		t.Errorf("checker.New(redis with password) = %+v %v", chk, err)
+	}
+	cfg.Checker.Params["password"] = 1.0
+	if _, err := New(cfg); err == nil {
+		t.Errorf("checker.New(redis with invalid password) doesn't return error")
+	}
+}
+
+func TestCheckCanceled(t *testing.T) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	hang := func(conn net.Conn, r *bufio.Reader) { io.Copy(io.Discard, r) } // never replies
+	u := fakeServer(t, hang)
+	tests := []ConnectionChecker{
+		HTTP{Path: "/", Timeout: 10 * time.Second},
+		Redis{Timeout: 10 * time.Second},
+		Exec{Command: []string{"sh", "-c", "exec sleep 10"}, Timeout: 10 * time.Second},
+		Composite{Mode: AllType, Checks: []SubCheck{{Name: "redis", Checker: Redis{Timeout: 10 * time.Second}}}},
+	}
+	for _, chk := range tests {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		start := time.Now()
+		if chk.Check(ctx, u).Healthy {
+			t.Errorf("%T.Check(canceled) = true, want false", chk)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("%T.Check(canceled) took %s, want it to stop on cancel", chk, elapsed)
+		}
+		cancel()
+	}
+}
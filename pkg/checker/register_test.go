@@ -0,0 +1,57 @@
+package checker_test
+
+import (
+	"context"
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/checker"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+// static is a checker with a fixed result, registered like a module embedding the load balancer
+type static struct {
+	healthy bool
+}
+
+func (c static) Check(context.Context, *url.URL) checker.Result {
+	if c.healthy {
+		return checker.Result{Healthy: true}
+	}
+	return checker.Result{Reason: "static"}
+}
+
+func TestRegister(t *testing.T) {
+	checker.Register("static", func(params struct {
+		Healthy bool `json:"healthy"`
+	}, cfg *configs.Config) (checker.ConnectionChecker, error) {
+		return static{healthy: params.Healthy}, nil
+	})
+	if !slices.Contains(checker.Names(), "static") || !slices.Contains(checker.Names(), checker.HTTPType) {
+		t.Errorf("checker.Names() = %v, want built-in and registered checkers", checker.Names())
+	}
+
+	cfg := &configs.Config{Checker: configs.Checker{Name: "static", Params: map[string]any{"healthy": true}}}
+	chk, err := checker.New(cfg)
+	if err != nil || chk != (static{healthy: true}) {
+		t.Fatalf("checker.New(static) = %+v %v, want a healthy static checker", chk, err)
+	}
+
+	// registered checkers are sub checkers of composites too
+	cfg = &configs.Config{Checker: configs.Checker{Name: checker.AnyType, Params: map[string]any{
+		"checks": []any{map[string]any{"name": "static"}, map[string]any{"name": "static", "params": map[string]any{
+			"healthy": true}}},
+	}}}
+	if chk, err = checker.New(cfg); err != nil {
+		t.Fatalf("checker.New(any of static) returns error: %s", err)
+	}
+	u, _ := url.Parse("http://localhost")
+	if res := chk.Check(context.Background(), u); !res.Healthy || len(res.Checks) != 2 || res.Checks[0].Healthy {
+		t.Errorf("Composite.Check() = %+v, want healthy with the first check failed", res)
+	}
+
+	cfg = &configs.Config{Checker: configs.Checker{Name: "static", Params: map[string]any{"healthy": "yes"}}}
+	if _, err := checker.New(cfg); err == nil {
+		t.Errorf("checker.New(static, invalid params) doesn't return error")
+	}
+}
@@ -0,0 +1,80 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxSnippet is the number of response bytes kept in a result
+const maxSnippet = 256
+
+// Result is the outcome of a health check
+type Result struct {
+	Healthy    bool
+	Time       time.Time // start of the check
+	Latency    time.Duration
+	Reason     string   // why the check failed, empty if healthy
+	StatusCode int      // HTTP status code, 0 for other checkers
+	Snippet    string   // beginning of the response body or command output
+	Name       string   // name of a sub check
+	Checks     []Result // results of sub checks of a composite
+}
+
+func (r Result) String() string {
+	var b strings.Builder
+	if r.Name != "" {
+		b.WriteString(r.Name + ": ")
+	}
+	if r.Healthy {
+		b.WriteString("up")
+	} else {
+		b.WriteString("down")
+	}
+	fmt.Fprintf(&b, " in %s", r.Latency.Round(time.Microsecond))
+	if r.StatusCode != 0 {
+		fmt.Fprintf(&b, ", status code %d", r.StatusCode)
+	}
+	if r.Reason != "" {
+		b.WriteString(", " + r.Reason)
+	}
+	if len(r.Checks) > 0 {
+		subs := make([]string, len(r.Checks))
+		for i, sub := range r.Checks {
+			subs[i] = sub.String()
+		}
+		b.WriteString(" (" + strings.Join(subs, "; ") + ")")
+	}
+	if r.Snippet != "" {
+		fmt.Fprintf(&b, ", response: %q", r.Snippet)
+	}
+	return b.String()
+}
+
+// begin starts the result of a check
+func begin() Result {
+	return Result{Time: time.Now()}
+}
+
+// pass completes r as healthy
+func (r Result) pass() Result {
+	r.Healthy = true
+	r.Latency = time.Since(r.Time)
+	return r
+}
+
+// fail completes r as unhealthy with the formatted reason
+func (r Result) fail(format string, args ...any) Result {
+	r.Healthy = false
+	r.Latency = time.Since(r.Time)
+	r.Reason = fmt.Sprintf(format, args...)
+	return r
+}
+
+// snippet returns the beginning of a response as valid UTF-8
+func snippet(b []byte) string {
+	if len(b) > maxSnippet {
+		b = b[:maxSnippet]
+	}
+	return strings.ToValidUTF8(string(b), "")
+}
@@ -0,0 +1,46 @@
+package checker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPCheckResult(t *testing.T) {
+	body := strings.Repeat("x", 2*maxSnippet)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		rw.Write([]byte(body))
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	start := time.Now()
+	res := HTTP{Path: "/", Timeout: time.Second}.Check(context.Background(), u)
+	if res.Healthy || res.StatusCode != http.StatusServiceUnavailable || res.Reason == "" {
+		t.Errorf("HTTP.Check(503) = %+v, want unhealthy with status code and reason", res)
+	}
+	if res.Snippet != body[:maxSnippet] {
+		t.Errorf("HTTP.Check(503) snippet has %d bytes, want %d", len(res.Snippet), maxSnippet)
+	}
+	if res.Latency < 10*time.Millisecond || res.Time.Before(start) {
+		t.Errorf("HTTP.Check(503) time = %s, latency = %s, want latency of at least 10ms", res.Time, res.Latency)
+	}
+}
+
+func TestResultString(t *testing.T) {
+	res := Result{Latency: 1500 * time.Microsecond, Reason: "1 of 2 checks failed", Checks: []Result{
+		{Name: "db", Healthy: true, Latency: time.Millisecond},
+		{Name: "http", Latency: time.Millisecond, StatusCode: 503, Reason: "unexpected status code", Snippet: "busy"},
+	}}
+	want := `down in 1.5ms, 1 of 2 checks failed (db: up in 1ms; ` +
+		`http: down in 1ms, status code 503, unexpected status code, response: "busy")`
+	if got := res.String(); got != want {
+		t.Errorf("Result.String() = %s, want %s", got, want)
+	}
+}
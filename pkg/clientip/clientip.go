@@ -0,0 +1,79 @@
+package clientip
+
+import (
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/internal/netutil"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Resolver finds the real client IP of a request. Forwarding headers are only honored when they were added by
+// trusted proxies. A nil Resolver trusts no proxy.
+type Resolver struct {
+	TrustedProxies netutil.CIDRList
+}
+
+func New(cfg *configs.Config) (*Resolver, error) {
+	trusted, err := netutil.ParseCIDRList(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	return &Resolver{TrustedProxies: trusted}, nil
+}
+
+// ClientIP walks the forwarding chain (Forwarded, or X-Forwarded-For if absent) from the right and returns the
+// first address which is not a trusted proxy. r.RemoteAddr is returned as is if it isn't an IP address.
+func (res *Resolver) ClientIP(r *http.Request) string {
+	ip := netutil.HostIP(r.RemoteAddr)
+	if ip == nil {
+		return r.RemoteAddr
+	}
+	if !res.trusted(ip) {
+		return ip.String()
+	}
+
+	chain := forwardedFor(r.Header)
+	for i := len(chain) - 1; i >= 0; i-- {
+		hop := netutil.HostIP(chain[i])
+		if hop == nil { // unknown or obfuscated hop, cannot go further
+			break
+		}
+		ip = hop
+		if !res.trusted(ip) {
+			break
+		}
+	}
+	return ip.String()
+}
+
+func (res *Resolver) trusted(ip net.IP) bool {
+	return res != nil && res.TrustedProxies.Contains(ip)
+}
+
+// forwardedFor returns hops of the forwarding chain, client first
+func forwardedFor(h http.Header) []string {
+	var chain []string
+	if values := h.Values("Forwarded"); len(values) > 0 {
+		for _, v := range values {
+			for _, element := range strings.Split(v, ",") {
+				hop := ""
+				for _, pair := range strings.Split(element, ";") {
+					key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+					if strings.EqualFold(key, "for") {
+						hop = strings.Trim(value, `"`)
+					}
+				}
+				chain = append(chain, hop)
+			}
+		}
+		return chain
+	}
+
+	for _, v := range h.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(v, ",") {
+			chain = append(chain, strings.TrimSpace(hop))
+		}
+	}
+	return chain
+}
@@ -0,0 +1,60 @@
+package clientip
+
+import (
+	"github.com/samanazadi/load-balancer/configs"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	res, err := New(&configs.Config{TrustedProxies: []string{"10.0.0.0/8", "2001:db8::/32"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		forwarded  []string
+		want       string
+	}{
+		{"NoHeaders", "203.0.113.1:5000", nil, nil, "203.0.113.1"},
+		{"UntrustedRemote", "203.0.113.1:5000", []string{"198.51.100.1"}, nil, "203.0.113.1"},
+		{"TrustedRemote", "10.0.0.1:5000", []string{"198.51.100.1"}, nil, "198.51.100.1"},
+		{"SpoofedLeftmost", "10.0.0.1:5000", []string{"1.1.1.1, 198.51.100.1, 10.0.0.2"}, nil, "198.51.100.1"},
+		{"MultipleHeaders", "10.0.0.1:5000", []string{"1.1.1.1", "198.51.100.1"}, nil, "198.51.100.1"},
+		{"AllTrusted", "10.0.0.1:5000", []string{"10.0.0.3, 10.0.0.2"}, nil, "10.0.0.3"},
+		{"InvalidHop", "10.0.0.1:5000", []string{"198.51.100.1, garbage"}, nil, "10.0.0.1"},
+		{"Forwarded", "10.0.0.1:5000", nil, []string{`for=1.1.1.1, for="[2001:db8::1]:4711";proto=https, for=198.51.100.9`}, "198.51.100.9"},
+		{"ForwardedIPv6", "10.0.0.1:5000", nil, []string{`for="[2001:db9::1]:4711";proto=https`}, "2001:db9::1"},
+		{"ForwardedPrecedence", "10.0.0.1:5000", []string{"198.51.100.1"}, []string{"for=198.51.100.2"}, "198.51.100.2"},
+		{"ForwardedUnknown", "10.0.0.1:5000", nil, []string{"for=unknown"}, "10.0.0.1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = test.remoteAddr
+			for _, v := range test.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			for _, v := range test.forwarded {
+				r.Header.Add("Forwarded", v)
+			}
+			if got := res.ClientIP(r); got != test.want {
+				t.Errorf("Resolver.ClientIP() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNilResolver(t *testing.T) {
+	var res *Resolver
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	if got := res.ClientIP(r); got != "10.0.0.1" {
+		t.Errorf("(*Resolver)(nil).ClientIP() = %s, want 10.0.0.1", got)
+	}
+}
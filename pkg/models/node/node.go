@@ -0,0 +1,407 @@
+package node
+
+import (
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/breaker"
+	"github.com/samanazadi/load-balancer/pkg/checker"
+	"github.com/samanazadi/load-balancer/pkg/logging"
+	"github.com/samanazadi/load-balancer/pkg/retry"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Node is a single backend server
+type Node struct {
+	URL          *url.URL
+	HealthURL    *url.URL                  // passive health check target, URL if nil
+	Checker      checker.ConnectionChecker // passive health checker, the pool's if nil
+	alive        bool
+	disabled     bool             // taken out of rotation by the admin
+	draining     bool             // gets no new requests, in-flight ones finish
+	drained      chan struct{}    // closed when a draining node has no in-flight request
+	health       Health           // passive health check state
+	checks       []checker.Result // last passive health check results, oldest first
+	history      int              // capacity of checks
+	upSince      time.Time        // last transition to alive, zero if alive since creation
+	ejectedUntil time.Time        // ejected by outlier detection
+	weight       int
+	active       atomic.Int64 // in-flight requests
+	ReverseProxy *httputil.ReverseProxy
+	Breaker      *breaker.Breaker // nil if disabled
+	SlowStart    *SlowStart       // nil if disabled
+	transport    *observer
+	mux          sync.RWMutex // for protecting alive, disabled, draining, drained, health, checks, upSince, ejectedUntil and weight
+}
+
+// Health is the passive health check state of a node
+type Health struct {
+	Alive          bool
+	Unknown        bool // not checked yet, the next check decides the state
+	Successes      int  // consecutive successful checks
+	Failures       int  // consecutive failed checks
+	Transitions    int  // up/down transitions
+	LastTransition time.Time
+}
+
+func (n *Node) SetAlive(alive bool) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.setAlive(alive)
+	n.health.Unknown = false
+	n.health.Successes, n.health.Failures = 0, 0
+}
+
+func (n *Node) setAlive(alive bool) {
+	if alive != n.alive {
+		n.health.Transitions++
+		n.health.LastTransition = time.Now()
+		if alive {
+			n.upSince = n.health.LastTransition
+		}
+	}
+	n.alive = alive
+}
+
+// SetUnknown sets the state of a node which isn't checked yet. The next check decides its state regardless of rise
+// and fall.
+func (n *Node) SetUnknown(alive bool) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.alive = alive
+	n.health.Unknown = true
+	n.health.Successes, n.health.Failures = 0, 0
+}
+
+// ApplyCheck records a passive health check result. A dead node comes up after rise consecutive successful checks
+// and an alive node goes down after fall consecutive failed checks. It reports whether the node state changed.
+func (n *Node) ApplyCheck(res checker.Result, rise, fall int) bool {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	history := n.history
+	if history <= 0 {
+		history = configs.DefaultHistory
+	}
+	if len(n.checks) >= history {
+		n.checks = append(n.checks[:0], n.checks[len(n.checks)-history+1:]...)
+	}
+	n.checks = append(n.checks, res)
+
+	healthy := res.Healthy
+	if healthy {
+		n.health.Successes++
+		n.health.Failures = 0
+	} else {
+		n.health.Failures++
+		n.health.Successes = 0
+	}
+
+	switch {
+	case n.health.Unknown:
+		n.health.Unknown = false
+		if n.alive == healthy {
+			return false
+		}
+		n.setAlive(healthy)
+	case !n.alive && healthy && n.health.Successes >= rise:
+		n.setAlive(true)
+	case n.alive && !healthy && n.health.Failures >= fall:
+		n.setAlive(false)
+	default:
+		return false
+	}
+	return true
+}
+
+// Health returns a snapshot of the passive health check state
+func (n *Node) Health() Health {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	h := n.health
+	h.Alive = n.alive
+	return h
+}
+
+// Checks returns the last passive health check results, oldest first
+func (n *Node) Checks() []checker.Result {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return append([]checker.Result(nil), n.checks...)
+}
+
+// SetEnabled takes the node in or out of rotation regardless of its health
+func (n *Node) SetEnabled(enabled bool) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.disabled = !enabled
+}
+
+func (n *Node) IsEnabled() bool {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return !n.disabled
+}
+
+// Drain stops new requests to the node, including new sessions of sticky algorithms, while in-flight requests and
+// upgraded connections finish. The returned channel is closed when no request is in flight.
+func (n *Node) Drain() <-chan struct{} {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if !n.draining {
+		n.draining = true
+		n.drained = make(chan struct{})
+		n.closeDrained()
+	}
+	return n.drained
+}
+
+// Undrain puts a draining node back into rotation. The channel returned by Drain is closed, so waiters wake up and
+// find the node no longer draining.
+func (n *Node) Undrain() {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if n.drained != nil {
+		select {
+		case <-n.drained:
+		default:
+			close(n.drained)
+		}
+	}
+	n.draining = false
+	n.drained = nil
+}
+
+func (n *Node) IsDraining() bool {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return n.draining
+}
+
+// Drained returns the channel closed when the draining node has no request in flight, nil if it isn't draining
+func (n *Node) Drained() <-chan struct{} {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return n.drained
+}
+
+// IsDrained reports whether the node is draining and no request is in flight
+func (n *Node) IsDrained() bool {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return n.draining && n.active.Load() == 0
+}
+
+// closeDrained closes drained if the node is draining and no request is in flight. It must be called with mux held.
+func (n *Node) closeDrained() {
+	if !n.draining || n.active.Load() != 0 {
+		return
+	}
+	select {
+	case <-n.drained:
+	default:
+		close(n.drained)
+		logging.Logger.Printf("%s drained", n.URL)
+	}
+}
+
+func (n *Node) IsAlive() bool {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return n.alive
+}
+
+func (n *Node) SetWeight(weight int) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.weight = weight
+}
+
+// Weight returns the configured weight, 1 if not set
+func (n *Node) Weight() int {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	if n.weight <= 0 {
+		return 1
+	}
+	return n.weight
+}
+
+// EffectiveWeight returns the weight reduced by slow start while the node is recovering
+func (n *Node) EffectiveWeight() float64 {
+	w := float64(n.Weight())
+	n.mux.RLock()
+	upSince := n.upSince
+	n.mux.RUnlock()
+	if upSince.IsZero() {
+		return w
+	}
+	return w * n.SlowStart.Factor(time.Since(upSince))
+}
+
+// Active returns the number of in-flight requests
+func (n *Node) Active() int64 {
+	return n.active.Load()
+}
+
+// ServeHTTP proxies the request to the node, counting it as in-flight
+func (n *Node) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	n.active.Add(1)
+	n.Serve(rw, r)
+}
+
+// Acquire counts a request as in-flight unless the node is draining. The count is taken before draining is checked,
+// so a concurrent Drain either sees the request or is seen by Acquire, never neither.
+func (n *Node) Acquire() bool {
+	n.active.Add(1)
+	if n.IsDraining() {
+		n.done()
+		return false
+	}
+	return true
+}
+
+// Serve proxies a request counted by Acquire to the node
+func (n *Node) Serve(rw http.ResponseWriter, r *http.Request) {
+	defer n.done()
+	n.ReverseProxy.ServeHTTP(rw, r)
+}
+
+func (n *Node) done() {
+	if n.active.Add(-1) != 0 {
+		return
+	}
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.closeDrained()
+}
+
+// HealthTarget returns the URL probed by passive health check
+func (n *Node) HealthTarget() *url.URL {
+	if n.HealthURL != nil {
+		return n.HealthURL
+	}
+	return n.URL
+}
+
+// Eject takes the node out of rotation until the given time of the real clock, which IsEjected compares with
+func (n *Node) Eject(until time.Time) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.ejectedUntil = until
+}
+
+func (n *Node) IsEjected() bool {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return time.Now().Before(n.ejectedUntil)
+}
+
+// SetTransport replaces the transport used to send requests to the node
+func (n *Node) SetTransport(rt http.RoundTripper) {
+	n.transport.next = rt
+}
+
+// Outcome is the observed result of a request sent to a node
+type Outcome struct {
+	StatusCode int   // zero if Err is set
+	Err        error // transport error
+	Latency    time.Duration
+}
+
+type LB interface {
+	SetNodeAlive(*url.URL, bool)
+	ReportOutcome(*Node, Outcome)
+}
+
+func New(url *url.URL, alive bool, cfg *configs.Config, lb LB) *Node {
+	rp := httputil.NewSingleHostReverseProxy(url)
+	n := &Node{
+		URL:          url,
+		ReverseProxy: rp,
+	}
+	n.transport = &observer{node: n, lb: lb, next: http.DefaultTransport}
+	rp.Transport = n.transport
+	rp.ErrorHandler = newReverseProxyErrorHandler()
+	rp.ModifyResponse = newReverseProxyModifyResponse()
+	if cfg != nil {
+		n.Breaker = breaker.New(cfg)
+		n.SlowStart = NewSlowStart(cfg)
+		n.history = cfg.Checker.History
+	}
+	if n.Breaker != nil {
+		n.Breaker.OnStateChange = func(from, to breaker.State) {
+			logging.Logger.Printf("active health check, circuit breaker, %s: %s -> %s", url, from, to)
+		}
+	}
+	n.alive = alive
+	return n
+}
+
+func newReverseProxyErrorHandler() func(http.ResponseWriter, *http.Request, error) {
+	return func(rw http.ResponseWriter, r *http.Request, e error) {
+		if s := retry.FromContext(r.Context()); s != nil && s.Retry(r.Context(), e) {
+			return // load balancer sends it to another node
+		}
+		rw.WriteHeader(http.StatusBadGateway)
+	}
+}
+
+func newReverseProxyModifyResponse() func(*http.Response) error {
+	return func(res *http.Response) error {
+		ctx := res.Request.Context()
+		if s := retry.FromContext(ctx); s != nil && s.CanRetryStatus(ctx, res.StatusCode) {
+			return &retry.StatusError{StatusCode: res.StatusCode}
+		}
+		return nil
+	}
+}
+
+type TestCase struct {
+	URL   *url.URL
+	Alive bool
+	SetTo bool
+	Want  bool
+	Node  *Node
+}
+
+// CreateFakeNodes create some teste cases
+func CreateFakeNodes() ([]*Node, []*TestCase) {
+	var tests []*TestCase
+	urls := []string{
+		"http://localhost:8001",
+		"http://localhost:8002",
+		"http://localhost:8003",
+		"http://localhost:8004",
+	}
+	alives := []bool{true, true, false, false}
+	setTos := []bool{true, false, true, false}
+
+	var nodes []*Node
+	for i := range urls {
+		uu, _ := url.Parse(urls[i])
+		n := Node{
+			URL: uu,
+		}
+		nodes = append(nodes, &n)
+
+		tests = append(tests, &TestCase{
+			URL:   uu,
+			Alive: alives[i],
+			SetTo: setTos[i],
+			Want:  setTos[i],
+			Node:  &n,
+		})
+	}
+	return nodes, tests
+}
+
+// AliveToString return string represetation of an alive field
+func AliveToString(alive bool) string {
+	if alive {
+		return "Alive"
+	}
+	return "Dead"
+}
@@ -0,0 +1,272 @@
+package node
+
+import (
+	"fmt"
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/pkg/breaker"
+	"github.com/samanazadi/load-balancer/pkg/checker"
+	"github.com/samanazadi/load-balancer/pkg/logging"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSetAlive(t *testing.T) {
+	_, tests := CreateFakeNodes()
+
+	for _, test := range tests {
+		name := fmt.Sprintf("%sTo%s", AliveToString(test.Alive), AliveToString(test.SetTo))
+		t.Run(name, func(t *testing.T) {
+			test.Node.SetAlive(test.SetTo)
+			if test.Node.alive != test.Want {
+				t.Error("Node.IsAlive() = true")
+			}
+			if test.Node.URL.String() != test.URL.String() {
+				t.Error("Node.IsAlive() changed URL")
+			}
+		})
+	}
+}
+
+func TestIsAlive(t *testing.T) {
+	urlStr := "localhost:8001"
+	uu, _ := url.Parse(urlStr)
+	node := &Node{
+		URL:   uu,
+		alive: false,
+	}
+
+	if node.IsAlive() {
+		t.Error("Node.IsAlive() = true")
+	}
+	if node.URL.String() != urlStr {
+		t.Error("Node.IsAlive() changed URL")
+	}
+
+	node = &Node{
+		URL:   uu,
+		alive: true,
+	}
+
+	if !node.IsAlive() {
+		t.Error("Node.IsAlive() = false")
+	}
+	if node.URL.String() != urlStr {
+		t.Error("Node.IsAlive() changed URL")
+	}
+}
+
+func TestNewAlive(t *testing.T) {
+	urlStr := "localhost:8001"
+	uu, _ := url.Parse(urlStr)
+	node := New(uu, true, nil, nil)
+
+	if !node.alive {
+		t.Error("node.New(*, true, *, *).alive = false")
+	}
+	if node.URL.String() != urlStr {
+		t.Errorf("node.New(%s, true, *, *).URL = %s", urlStr, node.URL.String())
+	}
+}
+
+func TestNewDead(t *testing.T) {
+	urlStr := "localhost:8001"
+	uu, _ := url.Parse(urlStr)
+	node := New(uu, false, nil, nil)
+
+	if node.alive {
+		t.Error("node.New(*, false, *, *).alive = true")
+	}
+	if node.URL.String() != urlStr {
+		t.Errorf("node.New(%s, false, *, *).URL = %s", urlStr, node.URL.String())
+	}
+}
+
+func TestAliveMethods(t *testing.T) {
+	urlStr := "localhost:8001"
+	uu, _ := url.Parse(urlStr)
+	node := New(uu, false, nil, nil)
+	node.SetAlive(node.IsAlive()) // no change
+
+	if node.IsAlive() {
+		t.Error("Node.IsAlive() = false")
+	}
+	if node.URL.String() != urlStr {
+		t.Error("Node.IsAlive() changed URL")
+	}
+}
+
+func TestBreakerOpensOnFailures(t *testing.T) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	server.Close() // node is down
+	uu, _ := url.Parse(server.URL)
+	cfg := &configs.Config{}
+	cfg.HealthCheck.Active.MaxRetry = 2
+	node := New(uu, true, cfg, nil)
+
+	for i := 0; i < 2; i++ {
+		rw := httptest.NewRecorder()
+		node.ReverseProxy.ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+		if rw.Code != http.StatusBadGateway {
+			t.Errorf("Node.ReverseProxy.ServeHTTP(down node) = %d, want %d", rw.Code, http.StatusBadGateway)
+		}
+	}
+	if got := node.Breaker.State(); got != breaker.Open {
+		t.Errorf("Node.Breaker.State() = %s after 2 failures, want open", got)
+	}
+	if !node.IsAlive() {
+		t.Errorf("Node.IsAlive() = false, failures should only open the circuit")
+	}
+}
+
+func TestApplyCheck(t *testing.T) {
+	uu, _ := url.Parse("localhost:8001")
+	node := &Node{URL: uu, alive: true}
+
+	results := []bool{false, false, true, false, false, false, true, true}
+	wants := []bool{true, true, true, true, true, false, false, true}
+	changes := []bool{false, false, false, false, false, true, false, true}
+	for i, healthy := range results {
+		changed := node.ApplyCheck(checker.Result{Healthy: healthy}, 2, 3)
+		if node.IsAlive() != wants[i] || changed != changes[i] {
+			t.Errorf("check %d: Node.ApplyCheck(%t, rise=2, fall=3) = %t, alive = %t, want %t, alive = %t",
+				i+1, healthy, changed, node.IsAlive(), changes[i], wants[i])
+		}
+	}
+	if h := node.Health(); h.Transitions != 2 || h.Successes != 2 || h.Failures != 0 {
+		t.Errorf("Node.Health() = %+v, want 2 transitions and 2 successes", h)
+	}
+}
+
+func TestApplyCheckUnknown(t *testing.T) {
+	uu, _ := url.Parse("localhost:8001")
+	for _, alive := range []bool{true, false} {
+		for _, healthy := range []bool{true, false} {
+			node := &Node{URL: uu}
+			node.SetUnknown(alive)
+			changed := node.ApplyCheck(checker.Result{Healthy: healthy}, 3, 3)
+			if node.IsAlive() != healthy || changed != (alive != healthy) || node.Health().Unknown {
+				t.Errorf("unknown node (alive = %t) Node.ApplyCheck(%t, rise=3, fall=3) = %t, alive = %t, want %t, alive = %t",
+					alive, healthy, changed, node.IsAlive(), alive != healthy, healthy)
+			}
+		}
+	}
+}
+
+func TestChecksHistory(t *testing.T) {
+	uu, _ := url.Parse("localhost:8001")
+	node := New(uu, true, &configs.Config{Checker: configs.Checker{History: 3}}, nil)
+	for i := 1; i <= 5; i++ {
+		node.ApplyCheck(checker.Result{Healthy: i%2 == 0, StatusCode: i}, 1, 1)
+	}
+	checks := node.Checks()
+	if len(checks) != 3 {
+		t.Fatalf("Node.Checks() has %d results, want 3", len(checks))
+	}
+	for i, res := range checks {
+		if res.StatusCode != i+3 {
+			t.Errorf("Node.Checks()[%d].StatusCode = %d, want %d", i, res.StatusCode, i+3)
+		}
+	}
+	checks[0].StatusCode = 0
+	if node.Checks()[0].StatusCode != 3 {
+		t.Errorf("Node.Checks() returned the internal history")
+	}
+}
+
+func TestDrain(t *testing.T) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { <-release }))
+	defer server.Close()
+	uu, _ := url.Parse(server.URL)
+	node := New(uu, true, &configs.Config{}, nil)
+
+	served := make(chan struct{})
+	go func() {
+		node.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		close(served)
+	}()
+	for node.Active() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	drained := node.Drain()
+	if !node.IsDraining() || node.IsDrained() {
+		t.Errorf("Node.Drain() with a request in flight: draining = %t, drained = %t, want draining only",
+			node.IsDraining(), node.IsDrained())
+	}
+	select {
+	case <-drained:
+		t.Fatal("Node.Drain() channel is closed with a request in flight")
+	default:
+	}
+
+	close(release)
+	<-served
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatal("Node.Drain() channel isn't closed after the in-flight request finished")
+	}
+	if !node.IsDrained() {
+		t.Errorf("Node.IsDrained() = false after the in-flight request finished")
+	}
+
+	node.Undrain()
+	if node.IsDraining() || node.Drained() != nil {
+		t.Errorf("Node.IsDraining() = %t after Undrain(), want false", node.IsDraining())
+	}
+	select {
+	case <-node.Drain():
+	default:
+		t.Errorf("Node.Drain() channel of an idle node isn't closed")
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	uu, _ := url.Parse("http://localhost:8001")
+	node := New(uu, true, &configs.Config{}, nil)
+
+	if !node.Acquire() || node.Active() != 1 {
+		t.Fatalf("Node.Acquire() of a node in rotation = false or not counted, active = %d", node.Active())
+	}
+	drained := node.Drain()
+	if node.Acquire() {
+		t.Errorf("Node.Acquire() of a draining node = true, want false")
+	}
+	if node.Active() != 1 {
+		t.Errorf("Node.Active() = %d after a failed Acquire(), want 1", node.Active())
+	}
+	node.done()
+	select {
+	case <-drained:
+	default:
+		t.Errorf("Node.Drain() channel isn't closed after the acquired request finished")
+	}
+}
+
+func TestUndrainWakesWaiters(t *testing.T) {
+	logging.Logger = log.New(io.Discard, "", 0)
+	uu, _ := url.Parse("http://localhost:8001")
+	node := New(uu, true, &configs.Config{}, nil)
+	node.Acquire()
+	defer node.done()
+
+	drained := node.Drain()
+	node.Undrain()
+	select {
+	case <-drained:
+	default:
+		t.Errorf("Node.Drain() channel isn't closed after Undrain()")
+	}
+	if node.IsDraining() {
+		t.Errorf("Node.IsDraining() = true after Undrain(), want false")
+	}
+}
@@ -0,0 +1,41 @@
+package node
+
+import (
+	"github.com/samanazadi/load-balancer/pkg/logging"
+	"net/http"
+	"time"
+)
+
+// observer is the transport of a node. It reports the outcome of every request to the circuit breaker of the node
+// and to the load balancer.
+type observer struct {
+	node *Node
+	lb   LB
+	next http.RoundTripper
+}
+
+func (o *observer) RoundTrip(r *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := o.next.RoundTrip(r)
+	outcome := Outcome{Err: err, Latency: time.Since(start)}
+
+	n := o.node
+	switch {
+	case err != nil && r.Context().Err() != nil: // canceled by client
+		n.Breaker.Cancel()
+		return res, err
+	case err != nil: // Active health check
+		logging.Logger.Printf("active health check, request failed: %s (%s)", n.URL, err.Error())
+		n.Breaker.Failure()
+	case res.StatusCode >= http.StatusInternalServerError:
+		outcome.StatusCode = res.StatusCode
+		n.Breaker.Failure()
+	default:
+		outcome.StatusCode = res.StatusCode
+		n.Breaker.Success()
+	}
+	if o.lb != nil {
+		o.lb.ReportOutcome(n, outcome)
+	}
+	return res, err
+}
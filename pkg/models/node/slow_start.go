@@ -0,0 +1,48 @@
+package node
+
+import (
+	"github.com/samanazadi/load-balancer/configs"
+	"math"
+	"time"
+)
+
+const (
+	LinearSlowStart      = "linear"
+	ExponentialSlowStart = "exponential"
+)
+
+// SlowStart ramps up the weight of a node over Window after it comes up. A nil SlowStart is disabled.
+type SlowStart struct {
+	Window      time.Duration
+	Exponential bool    // slow at first, fast at the end
+	MinWeight   float64 // fraction of the weight at the start of the window
+}
+
+// NewSlowStart returns nil if slow start is disabled
+func NewSlowStart(cfg *configs.Config) *SlowStart {
+	sc := cfg.SlowStart
+	if sc.Window <= 0 {
+		return nil
+	}
+	ss := &SlowStart{
+		Window:      time.Second * time.Duration(sc.Window),
+		Exponential: sc.Mode == ExponentialSlowStart,
+		MinWeight:   sc.MinWeight,
+	}
+	if ss.MinWeight <= 0 || ss.MinWeight > 1 {
+		ss.MinWeight = configs.DefaultSlowStartMinWeight
+	}
+	return ss
+}
+
+// Factor returns the fraction of the weight given to a node which has been up for the elapsed duration
+func (ss *SlowStart) Factor(elapsed time.Duration) float64 {
+	if ss == nil || elapsed >= ss.Window {
+		return 1
+	}
+	t := float64(elapsed) / float64(ss.Window)
+	if ss.Exponential {
+		t = (math.Pow(2, 10*t) - 1) / (math.Pow(2, 10) - 1)
+	}
+	return math.Max(ss.MinWeight, t)
+}
@@ -0,0 +1,66 @@
+package node
+
+import (
+	"github.com/samanazadi/load-balancer/configs"
+	"math"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestNewSlowStart(t *testing.T) {
+	if ss := NewSlowStart(&configs.Config{}); ss != nil {
+		t.Errorf("NewSlowStart(no window) != nil")
+	}
+	cfg := &configs.Config{SlowStart: configs.SlowStart{Window: 30, Mode: ExponentialSlowStart}}
+	ss := NewSlowStart(cfg)
+	if ss.Window != 30*time.Second || !ss.Exponential || ss.MinWeight != configs.DefaultSlowStartMinWeight {
+		t.Errorf("NewSlowStart(%+v) = %+v", cfg.SlowStart, ss)
+	}
+}
+
+func TestSlowStartFactor(t *testing.T) {
+	linear := &SlowStart{Window: 10 * time.Second, MinWeight: 0.1}
+	exponential := &SlowStart{Window: 10 * time.Second, MinWeight: 0.01, Exponential: true}
+	tests := []struct {
+		ss      *SlowStart
+		elapsed time.Duration
+		want    float64
+	}{
+		{nil, 0, 1},
+		{linear, 0, 0.1},
+		{linear, 5 * time.Second, 0.5},
+		{linear, 10 * time.Second, 1},
+		{linear, time.Hour, 1},
+		{exponential, 0, 0.01},
+		{exponential, 5 * time.Second, 31.0 / 1023},
+		{exponential, 9 * time.Second, 511.0 / 1023},
+	}
+	for _, test := range tests {
+		if got := test.ss.Factor(test.elapsed); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("SlowStart%+v.Factor(%s) = %f, want %f", test.ss, test.elapsed, got, test.want)
+		}
+	}
+}
+
+func TestEffectiveWeight(t *testing.T) {
+	uu, _ := url.Parse("localhost:8001")
+	node := New(uu, true, nil, nil)
+	node.SlowStart = &SlowStart{Window: time.Hour, MinWeight: 0.1}
+	node.SetWeight(10)
+
+	if got := node.EffectiveWeight(); got != 10 {
+		t.Errorf("Node.EffectiveWeight() = %f for a node alive since creation, want 10", got)
+	}
+	node.SetAlive(false)
+	node.SetAlive(true)
+	if got := node.EffectiveWeight(); got < 1 || got > 1.01 {
+		t.Errorf("Node.EffectiveWeight() = %f right after recovery, want 1", got)
+	}
+
+	node.SetWeight(0)
+	node.SlowStart = nil
+	if got := node.EffectiveWeight(); got != 1 {
+		t.Errorf("Node.EffectiveWeight() = %f without weight, want 1", got)
+	}
+}
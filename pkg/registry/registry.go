@@ -0,0 +1,104 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Validator is implemented by params which check their values after they are decoded
+type Validator interface {
+	Validate() error
+}
+
+// NoParams are the params of plugins which take none
+type NoParams struct{}
+
+// Registry holds the constructors of plugins of type T, like algorithms or checkers, by name. Every constructor takes
+// its own params type and the environment E, like the config.
+type Registry[T, E any] struct {
+	kind    string // like "algorithm", for errors
+	mux     sync.RWMutex
+	plugins map[string]func(params map[string]any, env E) (T, error)
+}
+
+// New returns an empty registry of plugins of kind, like "algorithm"
+func New[T, E any](kind string) *Registry[T, E] {
+	return &Registry[T, E]{kind: kind, plugins: make(map[string]func(map[string]any, E) (T, error))}
+}
+
+// Register adds the plugin name to r. Its params are decoded into P, which may implement Validator, before
+// newPlugin is called. It panics if name is empty or already registered, so it is meant to be called by init
+// functions.
+func Register[P, T, E any](r *Registry[T, E], name string, newPlugin func(params P, env E) (T, error)) {
+	if name == "" || newPlugin == nil {
+		panic(fmt.Sprintf("registry: invalid %s plugin %q", r.kind, name))
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if _, dup := r.plugins[name]; dup {
+		panic(fmt.Sprintf("registry: %s %q is registered twice", r.kind, name))
+	}
+	r.plugins[name] = func(m map[string]any, env E) (T, error) {
+		params, err := Decode[P](m)
+		if err != nil {
+			var zero T
+			return zero, fmt.Errorf("%s %s invalid params: %s", name, r.kind, err.Error())
+		}
+		return newPlugin(params, env)
+	}
+}
+
+// New creates the plugin name with params
+func (r *Registry[T, E]) New(name string, params map[string]any, env E) (T, error) {
+	r.mux.RLock()
+	newPlugin, ok := r.plugins[name]
+	r.mux.RUnlock()
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("invalid %s: %s, want one of %s", r.kind, name, strings.Join(r.Names(), ", "))
+	}
+	return newPlugin(params, env)
+}
+
+// Names returns the sorted names of the registered plugins
+func (r *Registry[T, E]) Names() []string {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	names := make([]string, 0, len(r.plugins))
+	for name := range r.plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Decode decodes params into P like a JSON object, rejecting unknown params, and validates it if P implements
+// Validator
+func Decode[P any](params map[string]any) (P, error) {
+	var p P
+	data, err := json.Marshal(params)
+	if err != nil {
+		return p, err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&p); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return p, fmt.Errorf("invalid %s: want %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value)
+		}
+		msg := strings.TrimPrefix(err.Error(), "json: ")
+		return p, errors.New(strings.Replace(msg, "unknown field", "unknown param", 1))
+	}
+	if v, ok := any(&p).(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,70 @@
+package registry
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type greeter string
+
+type greeterParams struct {
+	Name  string `json:"name"`
+	Times int    `json:"times"`
+}
+
+func (p greeterParams) Validate() error {
+	if p.Times < 1 {
+		return errors.New("invalid times")
+	}
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	r := New[greeter, string]("greeter")
+	Register(r, "hello", func(p greeterParams, prefix string) (greeter, error) {
+		return greeter(prefix + strings.Repeat("hello "+p.Name, p.Times)), nil
+	})
+	Register(r, "silent", func(NoParams, string) (greeter, error) { return "", nil })
+
+	if got := r.Names(); !reflect.DeepEqual(got, []string{"hello", "silent"}) {
+		t.Errorf("Registry.Names() = %v, want [hello silent]", got)
+	}
+	g, err := r.New("hello", map[string]any{"name": "x", "times": 2.0}, "> ")
+	if err != nil || g != "> hello xhello x" {
+		t.Errorf("Registry.New(hello) = %q %v, want \"> hello xhello x\"", g, err)
+	}
+	if _, err := r.New("silent", nil, ""); err != nil {
+		t.Errorf("Registry.New(silent, nil params) = %v, want no error", err)
+	}
+
+	invalid := []struct {
+		name   string
+		params map[string]any
+		want   string
+	}{
+		{"unknown", nil, "invalid greeter: unknown, want one of hello, silent"},
+		{"hello", map[string]any{"times": "2"}, "hello greeter invalid params: invalid times: want int, got string"},
+		{"hello", map[string]any{"times": 1.0, "nam": "x"}, `hello greeter invalid params: unknown param "nam"`},
+		{"hello", map[string]any{"name": "x"}, "hello greeter invalid params: invalid times"},
+		{"silent", map[string]any{"x": 1.0}, `silent greeter invalid params: unknown param "x"`},
+	}
+	for _, test := range invalid {
+		if _, err := r.New(test.name, test.params, ""); err == nil || err.Error() != test.want {
+			t.Errorf("Registry.New(%s, %v) = %v, want %q", test.name, test.params, err, test.want)
+		}
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	r := New[greeter, string]("greeter")
+	newGreeter := func(NoParams, string) (greeter, error) { return "", nil }
+	Register(r, "hello", newGreeter)
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Register(hello) twice doesn't panic")
+		}
+	}()
+	Register(r, "hello", newGreeter)
+}
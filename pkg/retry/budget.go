@@ -0,0 +1,90 @@
+package retry
+
+import (
+	"fmt"
+	"github.com/samanazadi/load-balancer/configs"
+	"github.com/samanazadi/load-balancer/internal/rolling"
+	"sync"
+	"time"
+)
+
+const defaultBudgetWindow = 10 // seconds
+
+// window series
+const (
+	requests = iota
+	retries
+)
+
+// Budget limits retries to a ratio of requests over a sliding window, so a degraded pool is not flooded by retries.
+// A nil Budget allows every retry.
+type Budget struct {
+	Ratio        float64    // retries per request
+	MinPerSecond float64    // retries which are always allowed
+	mux          sync.Mutex // for checking and counting a retry in one step
+	window       *rolling.Window
+}
+
+// NewBudget returns nil if the budget is not configured
+func NewBudget(bc configs.RetryBudget) (*Budget, error) {
+	if bc.Ratio == 0 && bc.MinPerSecond == 0 {
+		return nil, nil
+	}
+	if bc.Ratio < 0 || bc.MinPerSecond < 0 || bc.Window < 0 {
+		return nil, fmt.Errorf("retry invalid budget: %+v", bc)
+	}
+	window := bc.Window
+	if window == 0 {
+		window = defaultBudgetWindow
+	}
+	return &Budget{
+		Ratio:        bc.Ratio,
+		MinPerSecond: float64(bc.MinPerSecond),
+		window:       rolling.NewWindow(2, window, time.Second),
+	}, nil
+}
+
+// Request counts a new request
+func (b *Budget) Request() {
+	if b != nil {
+		b.window.Add(requests, 1)
+	}
+}
+
+// Allow reports whether another retry fits the budget, without reserving it
+func (b *Budget) Allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.allow()
+}
+
+func (b *Budget) allow() bool {
+	sums := b.window.Sums()
+	allowed := b.MinPerSecond*b.window.Length().Seconds() + b.Ratio*float64(sums[requests])
+	return float64(sums[retries]+1) <= allowed
+}
+
+// TryAcquire counts a retry if it fits the budget and reports whether it does. Checking and counting are one step, so
+// concurrent requests failing at once can't exceed the budget together.
+func (b *Budget) TryAcquire() bool {
+	if b == nil {
+		return true
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if !b.allow() {
+		return false
+	}
+	b.window.Add(retries, 1)
+	return true
+}
+
+// Release gives back a retry counted by TryAcquire which isn't made
+func (b *Budget) Release() {
+	if b != nil {
+		b.window.Add(retries, -1)
+	}
+}
@@ -0,0 +1,70 @@
+package retry
+
+import (
+	"github.com/samanazadi/load-balancer/configs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestNewBudget(t *testing.T) {
+	b, err := NewBudget(configs.RetryBudget{})
+	if b != nil || err != nil {
+		t.Errorf("NewBudget(empty) = %v, %v, want nil, nil", b, err)
+	}
+	if !b.Allow() {
+		t.Errorf("(*Budget)(nil).Allow() = false")
+	}
+	if _, err := NewBudget(configs.RetryBudget{Ratio: -1}); err == nil {
+		t.Errorf("NewBudget(negative ratio) doesn't return error")
+	}
+}
+
+func TestBudget(t *testing.T) {
+	b, _ := NewBudget(configs.RetryBudget{Ratio: 0.2, MinPerSecond: 1, Window: 2})
+	now := time.Unix(1000, 0)
+	b.window.Now = func() time.Time { return now }
+
+	// 2 retries from the minimum (1/s over 2s), 2 from 10 requests
+	for i := 0; i < 10; i++ {
+		b.Request()
+	}
+	for i := 0; i < 4; i++ {
+		if !b.Allow() || !b.TryAcquire() {
+			t.Fatalf("Budget.TryAcquire() = false for retry %d, want true", i+1)
+		}
+	}
+	if b.Allow() || b.TryAcquire() {
+		t.Errorf("Budget.TryAcquire() = true after the budget is spent")
+	}
+	b.Release()
+	if !b.TryAcquire() {
+		t.Errorf("Budget.TryAcquire() = false after a retry is released")
+	}
+
+	// window slides
+	now = now.Add(2 * time.Second)
+	if !b.Allow() {
+		t.Errorf("Budget.Allow() = false after the window slides")
+	}
+}
+
+func TestStateBudget(t *testing.T) {
+	p := newPolicy()
+	p.Budget, _ = NewBudget(configs.RetryBudget{MinPerSecond: 1, Window: 1})
+	pool, _ := NewBudget(configs.RetryBudget{Ratio: 1})
+	u, _ := url.Parse("http://localhost:8001")
+
+	s, r, _ := NewState(p, httptest.NewRequest(http.MethodGet, "/", nil), pool)
+	s.Start(r, u)
+	if !s.Retry(r.Context(), connectErr) {
+		t.Fatalf("State.Retry() = false within budget")
+	}
+	s.Wait(r.Context())
+	s.Start(r, u)
+	if s.Retry(r.Context(), connectErr) {
+		t.Errorf("State.Retry() = true after the global budget is spent")
+	}
+}
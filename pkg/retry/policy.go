@@ -0,0 +1,138 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/samanazadi/load-balancer/configs"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// error classes
+const (
+	ConnectFailure = "connect-failure" // request has not been sent, always safe to retry
+	Timeout        = "timeout"
+	Reset          = "reset"
+)
+
+// Policy decides whether a failed request is retried on another node
+type Policy struct {
+	MaxAttempts int             // including the first one
+	Methods     map[string]bool // methods which are retried after the request has been sent
+	StatusCodes map[int]bool    // response status codes which are retried
+	Errors      map[string]bool // error classes which are retried
+	BufferLimit int64           // max buffered request body, larger requests are not retried
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	TimeBudget  time.Duration // max time spent on all attempts of a request, 0 for unlimited
+	Budget      *Budget       // global retry budget
+}
+
+func New(cfg *configs.Config) (*Policy, error) {
+	rc := cfg.Retry
+	p := &Policy{
+		MaxAttempts: rc.MaxAttempts,
+		Methods:     make(map[string]bool),
+		StatusCodes: make(map[int]bool),
+		Errors:      make(map[string]bool),
+		BufferLimit: rc.BufferLimit,
+		BackoffBase: time.Millisecond * time.Duration(rc.BackoffBase),
+		BackoffMax:  time.Millisecond * time.Duration(rc.BackoffMax),
+		TimeBudget:  time.Millisecond * time.Duration(rc.TimeBudget),
+	}
+
+	// defaults
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = configs.DefaultMaxAttempts
+	}
+	if p.BufferLimit == 0 {
+		p.BufferLimit = configs.DefaultBufferLimit
+	}
+	if p.BackoffBase == 0 {
+		p.BackoffBase = time.Millisecond * configs.DefaultBackoffBase
+	}
+	if p.BackoffMax == 0 {
+		p.BackoffMax = time.Millisecond * configs.DefaultBackoffMax
+	}
+	methods, errs := rc.Methods, rc.Errors
+	if methods == nil {
+		methods = configs.DefaultRetryMethods
+	}
+	if errs == nil {
+		errs = configs.DefaultRetryErrors
+	}
+
+	for _, m := range methods {
+		p.Methods[strings.ToUpper(m)] = true
+	}
+	for _, code := range rc.StatusCodes {
+		if code < 100 || code > 599 {
+			return nil, fmt.Errorf("retry invalid status code: %d", code)
+		}
+		p.StatusCodes[code] = true
+	}
+	for _, e := range errs {
+		switch e {
+		case ConnectFailure, Timeout, Reset:
+			p.Errors[e] = true
+		default:
+			return nil, fmt.Errorf("retry invalid error class: %s", e)
+		}
+	}
+	budget, err := NewBudget(rc.Budget)
+	if err != nil {
+		return nil, err
+	}
+	p.Budget = budget
+	if _, err := NewBudget(rc.PoolBudget); err != nil {
+		return nil, err
+	}
+	if p.MaxAttempts < 1 || p.BufferLimit < 0 || p.BackoffBase < 0 || p.BackoffMax < p.BackoffBase || p.TimeBudget < 0 {
+		return nil, fmt.Errorf("retry invalid limits: %+v", rc)
+	}
+	return p, nil
+}
+
+// Backoff returns the delay before the given retry (1 for the first retry) using exponential backoff
+// with full jitter
+func (p *Policy) Backoff(retry int) time.Duration {
+	d := p.BackoffMax
+	if shift := retry - 1; shift < 32 && p.BackoffBase<<shift < p.BackoffMax {
+		d = p.BackoffBase << shift
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// StatusError is returned for responses whose status code should be retried
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("retryable status code: %d", e.StatusCode)
+}
+
+// Classify returns the class of a proxy error, or an empty string if it is not retryable
+func Classify(err error) string {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return ConnectFailure
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return Timeout
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return Reset
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/samanazadi/load-balancer/configs"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestNewDefaults(t *testing.T) {
+	p, err := New(&configs.Config{})
+	if err != nil {
+		t.Fatalf("retry.New(empty config) returns error: %s", err)
+	}
+	if p.MaxAttempts != configs.DefaultMaxAttempts {
+		t.Errorf("retry.New(empty config).MaxAttempts = %d, want %d", p.MaxAttempts, configs.DefaultMaxAttempts)
+	}
+	if !p.Methods[http.MethodGet] || p.Methods[http.MethodPost] {
+		t.Errorf("retry.New(empty config).Methods = %v, want idempotent methods", p.Methods)
+	}
+	for _, e := range configs.DefaultRetryErrors {
+		if !p.Errors[e] {
+			t.Errorf("retry.New(empty config).Errors doesn't contain %s", e)
+		}
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := map[string]configs.Retry{
+		"StatusCode": {StatusCodes: []int{700}},
+		"ErrorClass": {Errors: []string{"invalid"}},
+		"Attempts":   {MaxAttempts: -1},
+		"Backoff":    {BackoffBase: 100, BackoffMax: 10},
+	}
+	for name, rc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := New(&configs.Config{Retry: rc}); err == nil {
+				t.Errorf("retry.New(%+v) doesn't return error", rc)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := &Policy{BackoffBase: 10 * time.Millisecond, BackoffMax: 50 * time.Millisecond}
+	for retry, ceiling := range map[int]time.Duration{1: 10, 2: 20, 3: 40, 4: 50, 100: 50} {
+		for i := 0; i < 100; i++ {
+			if d := p.Backoff(retry); d < 0 || d > ceiling*time.Millisecond {
+				t.Fatalf("Policy.Backoff(%d) = %s, want in [0, %dms]", retry, d, ceiling)
+			}
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, ConnectFailure},
+		{&net.OpError{Op: "read", Err: syscall.ECONNRESET}, Reset},
+		{fmt.Errorf("wrapped: %w", io.ErrUnexpectedEOF), Reset},
+		{context.DeadlineExceeded, Timeout},
+		{errors.New("other"), ""},
+	}
+	for _, test := range tests {
+		if got := Classify(test.err); got != test.want {
+			t.Errorf("Classify(%v) = %q, want %q", test.err, got, test.want)
+		}
+	}
+}
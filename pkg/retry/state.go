@@ -0,0 +1,209 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+type stateKey struct{}
+
+// State tracks attempts of a single request. It is used by one goroutine (the one serving the request).
+type State struct {
+	policy     *Policy
+	budgets    []*Budget
+	method     string
+	start      time.Time
+	attempts   int
+	tried      map[string]bool // node URLs
+	body       []byte          // buffered request body
+	replayable bool
+	stream     *unreadBody // request body which isn't buffered, nil if replayable
+	last       bool        // current attempt is the last one
+	pending    bool        // current attempt failed and will be retried
+	reserved   bool        // a retry is reserved from the budgets until Wait
+	err        error
+}
+
+// NewState buffers the body of r (up to the policy limit) and returns the request carrying the state
+// in its context. The request is counted in the global budget of the policy and the given budgets.
+func NewState(p *Policy, r *http.Request, budgets ...*Budget) (*State, *http.Request, error) {
+	s := &State{
+		policy:     p,
+		budgets:    append([]*Budget{p.Budget}, budgets...),
+		method:     r.Method,
+		start:      time.Now(),
+		tried:      make(map[string]bool),
+		replayable: true,
+	}
+
+	if r.Body != nil && r.Body != http.NoBody {
+		if r.ContentLength > p.BufferLimit {
+			s.stream = &unreadBody{Reader: r.Body}
+		} else {
+			buf, err := io.ReadAll(io.LimitReader(r.Body, p.BufferLimit+1))
+			if err != nil {
+				return nil, nil, err
+			}
+			if int64(len(buf)) > p.BufferLimit { // unknown length, too large
+				s.stream = &unreadBody{Reader: io.MultiReader(bytes.NewReader(buf), r.Body)}
+			} else {
+				s.body = buf
+			}
+		}
+		if s.stream != nil {
+			s.replayable = false
+			r.Body = s.stream
+		}
+	}
+
+	for _, b := range s.budgets {
+		b.Request()
+	}
+	return s, r.WithContext(context.WithValue(r.Context(), stateKey{}, s)), nil
+}
+
+// FromContext returns the state of a request, or nil if it has none
+func FromContext(ctx context.Context) *State {
+	s, _ := ctx.Value(stateKey{}).(*State)
+	return s
+}
+
+// Tried reports whether r has already been sent to the node
+func Tried(r *http.Request, u *url.URL) bool {
+	if r == nil {
+		return false
+	}
+	s := FromContext(r.Context())
+	return s != nil && s.tried[u.String()]
+}
+
+// Start records a new attempt on the node and rewinds the body of r
+func (s *State) Start(r *http.Request, u *url.URL) {
+	s.attempts++
+	s.tried[u.String()] = true
+	s.last = s.attempts >= s.policy.MaxAttempts
+	s.pending = false
+	if s.body != nil {
+		r.Body = io.NopCloser(bytes.NewReader(s.body))
+	}
+}
+
+// SetLast marks the current attempt as the last one, e.g. when no other node is available
+func (s *State) SetLast() {
+	s.last = true
+}
+
+func (s *State) Attempts() int {
+	return s.attempts
+}
+
+// Err returns the error of the last failed attempt
+func (s *State) Err() error {
+	return s.err
+}
+
+// Pending reports whether the current attempt failed and should be retried
+func (s *State) Pending() bool {
+	return s.pending
+}
+
+// CanRetryStatus reports whether a response with the status code should be dropped and retried
+func (s *State) CanRetryStatus(ctx context.Context, statusCode int) bool {
+	return s.policy.StatusCodes[statusCode] && s.allowed(ctx, "")
+}
+
+// Retry records the failure of the current attempt and reports whether it will be retried
+func (s *State) Retry(ctx context.Context, err error) bool {
+	s.err = err
+	var se *StatusError
+	if errors.As(err, &se) { // already checked by CanRetryStatus
+		s.pending = true
+		return true
+	}
+	class := Classify(err)
+	s.pending = class != "" && s.policy.Errors[class] && s.allowed(ctx, class)
+	return s.pending
+}
+
+func (s *State) allowed(ctx context.Context, class string) bool {
+	if s.last || ctx.Err() != nil {
+		return false
+	}
+	// a body which isn't buffered can be sent again only if the failed attempt didn't read it
+	if !s.replayable && (class != ConnectFailure || s.stream.read.Load()) {
+		return false
+	}
+	if class != ConnectFailure && !s.policy.Methods[s.method] {
+		return false
+	}
+	if s.policy.TimeBudget > 0 && time.Since(s.start) >= s.policy.TimeBudget {
+		return false
+	}
+	if s.reserved {
+		return true
+	}
+	for i, b := range s.budgets {
+		if !b.TryAcquire() {
+			for _, acquired := range s.budgets[:i] {
+				acquired.Release()
+			}
+			return false
+		}
+	}
+	s.reserved = true
+	return true
+}
+
+// release gives back the retry reserved from the budgets by allowed
+func (s *State) release() {
+	if !s.reserved {
+		return
+	}
+	for _, b := range s.budgets {
+		b.Release()
+	}
+	s.reserved = false
+}
+
+// Wait sleeps before the next attempt, which keeps the retry reserved from the budgets when the attempt failed. It
+// returns false, giving the retry back to the budgets, if the request is done or the time budget doesn't allow
+// another attempt.
+func (s *State) Wait(ctx context.Context) bool {
+	d := s.policy.Backoff(s.attempts)
+	if s.policy.TimeBudget > 0 && time.Since(s.start)+d >= s.policy.TimeBudget {
+		s.release()
+		return false
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+		s.release()
+		return false
+	}
+	s.reserved = false
+	return true
+}
+
+// unreadBody is a request body too large to be buffered. The transport doesn't close it, so it can still be sent
+// after a failed attempt which didn't read it; the server closes the request body.
+type unreadBody struct {
+	io.Reader
+	read atomic.Bool
+}
+
+func (b *unreadBody) Read(p []byte) (int, error) {
+	b.read.Store(true)
+	return b.Reader.Read(p)
+}
+
+func (b *unreadBody) Close() error {
+	return nil
+}
@@ -0,0 +1,233 @@
+package retry
+
+import (
+	"context"
+	"github.com/samanazadi/load-balancer/configs"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var (
+	connectErr = &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+	resetErr   = &net.OpError{Op: "read", Err: syscall.ECONNRESET}
+)
+
+func newPolicy() *Policy {
+	return &Policy{
+		MaxAttempts: 3,
+		Methods:     map[string]bool{http.MethodGet: true},
+		StatusCodes: map[int]bool{http.StatusServiceUnavailable: true},
+		Errors:      map[string]bool{ConnectFailure: true, Reset: true},
+		BufferLimit: 8,
+		BackoffBase: time.Millisecond,
+		BackoffMax:  time.Millisecond,
+	}
+}
+
+func TestStateBodyReplay(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", strings.NewReader("payload"))
+	s, r, err := NewState(newPolicy(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("http://localhost:8001")
+	for i := 0; i < 2; i++ {
+		s.Start(r, u)
+		if body, _ := io.ReadAll(r.Body); string(body) != "payload" {
+			t.Errorf("attempt %d body = %q, want payload", i+1, body)
+		}
+	}
+	if FromContext(r.Context()) != s {
+		t.Errorf("FromContext(request) doesn't return its state")
+	}
+	if !Tried(r, u) {
+		t.Errorf("Tried(request, %s) = false after an attempt", u)
+	}
+}
+
+func TestStateLargeBody(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", strings.NewReader("a too large payload"))
+	r.ContentLength = -1 // unknown
+	s, r, err := NewState(newPolicy(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("http://localhost:8001")
+	s.Start(r, u)
+	if body, _ := io.ReadAll(r.Body); string(body) != "a too large payload" {
+		t.Errorf("large body = %q, want it untouched", body)
+	}
+	if s.Retry(r.Context(), connectErr) {
+		t.Errorf("State.Retry() = true for a request with a non-replayable body")
+	}
+}
+
+func TestStateLargeBodyUnread(t *testing.T) {
+	u, _ := url.Parse("http://localhost:8001")
+	for _, length := range []int64{-1, 19} { // unknown, over the limit
+		r := httptest.NewRequest(http.MethodGet, "/", strings.NewReader("a too large payload"))
+		r.ContentLength = length
+		s, r, _ := NewState(newPolicy(), r)
+		s.Start(r, u)
+		if s.Retry(r.Context(), resetErr) {
+			t.Errorf("State.Retry(reset) = true for a request with a non-replayable body of length %d", length)
+		}
+		if !s.Retry(r.Context(), connectErr) {
+			t.Errorf("State.Retry(connect failure) = false for a request with an unread body of length %d", length)
+		}
+		s.Start(r, u)
+		if body, _ := io.ReadAll(r.Body); string(body) != "a too large payload" {
+			t.Errorf("large body = %q after a connect failure, want it untouched", body)
+		}
+	}
+}
+
+func TestStateRetry(t *testing.T) {
+	u, _ := url.Parse("http://localhost:8001")
+	tests := []struct {
+		name   string
+		method string
+		err    error
+		want   bool
+	}{
+		{"IdempotentReset", http.MethodGet, resetErr, true},
+		{"NonIdempotentReset", http.MethodPost, resetErr, false},
+		{"NonIdempotentConnectFailure", http.MethodPost, connectErr, true},
+		{"DisabledClass", http.MethodGet, context.DeadlineExceeded, false},
+		{"Status", http.MethodGet, &StatusError{StatusCode: 503}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, r, _ := NewState(newPolicy(), httptest.NewRequest(test.method, "/", nil))
+			s.Start(r, u)
+			if got := s.Retry(r.Context(), test.err); got != test.want {
+				t.Errorf("State.Retry(%s, %v) = %t, want %t", test.method, test.err, got, test.want)
+			}
+			if s.Pending() != test.want || s.Err() != test.err {
+				t.Errorf("State.Retry() didn't record the failure")
+			}
+		})
+	}
+}
+
+func TestStateLastAttempt(t *testing.T) {
+	u, _ := url.Parse("http://localhost:8001")
+	s, r, _ := NewState(newPolicy(), httptest.NewRequest(http.MethodGet, "/", nil))
+	for i := 1; i <= 3; i++ {
+		s.Start(r, u)
+		want := i < 3
+		if got := s.Retry(r.Context(), connectErr); got != want {
+			t.Errorf("attempt %d: State.Retry() = %t, want %t", i, got, want)
+		}
+		if got := s.CanRetryStatus(r.Context(), http.StatusServiceUnavailable); got != want {
+			t.Errorf("attempt %d: State.CanRetryStatus(503) = %t, want %t", i, got, want)
+		}
+	}
+
+	s, r, _ = NewState(newPolicy(), httptest.NewRequest(http.MethodGet, "/", nil))
+	s.Start(r, u)
+	s.SetLast()
+	if s.Retry(r.Context(), connectErr) {
+		t.Errorf("State.Retry() = true after SetLast()")
+	}
+	if s.CanRetryStatus(r.Context(), http.StatusInternalServerError) {
+		t.Errorf("State.CanRetryStatus(500) = true for an unlisted status code")
+	}
+}
+
+func TestStateTimeBudget(t *testing.T) {
+	p := newPolicy()
+	p.TimeBudget = 10 * time.Millisecond
+	p.BackoffBase, p.BackoffMax = time.Second, time.Second
+	u, _ := url.Parse("http://localhost:8001")
+	s, r, _ := NewState(p, httptest.NewRequest(http.MethodGet, "/", nil))
+	s.Start(r, u)
+	start := time.Now()
+	for i := 0; i < 10 && s.Wait(r.Context()); i++ { // backoff is random, it may fit the budget a few times
+	}
+	if time.Since(start) > p.TimeBudget {
+		t.Errorf("State.Wait() exceeded the time budget")
+	}
+
+	time.Sleep(p.TimeBudget)
+	if s.Retry(r.Context(), connectErr) {
+		t.Errorf("State.Retry() = true after time budget is spent")
+	}
+}
+
+func TestStateWaitBudget(t *testing.T) {
+	b, _ := NewBudget(configs.RetryBudget{Ratio: 1}) // a retry per request
+	charged := func() int64 { return b.window.Sums()[retries] }
+	p := newPolicy()
+	p.TimeBudget = 10 * time.Millisecond
+	p.BackoffBase, p.BackoffMax = time.Second, time.Second
+	u, _ := url.Parse("http://localhost:8001")
+	s, r, _ := NewState(p, httptest.NewRequest(http.MethodGet, "/", nil), b)
+	s.Start(r, u)
+	if !s.Retry(r.Context(), connectErr) || charged() != 1 {
+		t.Fatalf("State.Retry() within budget = false or didn't reserve the retry")
+	}
+	if s.Wait(r.Context()) || charged() != 0 {
+		t.Errorf("State.Wait() over the time budget = true or kept the retry, %d retries", charged())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s, r, _ = NewState(newPolicy(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx), b)
+	s.Start(r, u)
+	s.Retry(r.Context(), connectErr)
+	cancel()
+	if s.Wait(ctx) || charged() != 0 {
+		t.Errorf("State.Wait() of a done request = true or kept the retry, %d retries", charged())
+	}
+
+	s, r, _ = NewState(newPolicy(), httptest.NewRequest(http.MethodGet, "/", nil), b)
+	s.Start(r, u)
+	s.Retry(r.Context(), connectErr)
+	if !s.Wait(r.Context()) || charged() != 1 {
+		t.Errorf("State.Wait() = false or gave the retry back, %d retries", charged())
+	}
+}
+
+func TestStateBudgetConcurrent(t *testing.T) {
+	const n = 100
+	b, _ := NewBudget(configs.RetryBudget{Ratio: 0.2}) // 20 retries for 100 requests
+	u, _ := url.Parse("http://localhost:8001")
+	states := make([]*State, n)
+	requests := make([]*http.Request, n)
+	for i := range states {
+		states[i], requests[i], _ = NewState(newPolicy(), httptest.NewRequest(http.MethodGet, "/", nil), b)
+		states[i].Start(requests[i], u)
+	}
+
+	// every request fails at once
+	start := make(chan struct{})
+	var allowed atomic.Int64
+	var wg sync.WaitGroup
+	for i := range states {
+		wg.Add(1)
+		go func(s *State, r *http.Request) {
+			defer wg.Done()
+			<-start
+			if s.Retry(r.Context(), connectErr) {
+				allowed.Add(1)
+			}
+		}(states[i], requests[i])
+	}
+	close(start)
+	wg.Wait()
+	if got := allowed.Load(); got != 20 {
+		t.Errorf("State.Retry() of %d concurrent failures allowed %d retries, want 20", n, got)
+	}
+	if got := b.window.Sums()[retries]; got != 20 {
+		t.Errorf("Budget counted %d retries, want 20", got)
+	}
+}